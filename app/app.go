@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"github.com/FatimaBabayeva/ms-go-example/auth"
+	"github.com/FatimaBabayeva/ms-go-example/handler"
+	"github.com/FatimaBabayeva/ms-go-example/health"
+	"github.com/FatimaBabayeva/ms-go-example/metrics"
+	"github.com/FatimaBabayeva/ms-go-example/middleware"
+	"github.com/FatimaBabayeva/ms-go-example/properties"
+	"github.com/FatimaBabayeva/ms-go-example/ratelimit"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/FatimaBabayeva/ms-go-example/service"
+	mid "github.com/go-chi/chi/middleware"
+	"github.com/go-pg/pg"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// unprotectedPaths are served to every caller, without authentication and rate limiting
+var unprotectedPaths = []string{"/health", "/readiness", "/metrics"}
+
+// App is a single instance of the service, its components are built from Config and wired explicitly
+type App struct {
+	Config  properties.Config
+	Router  *mux.Router
+	Service service.MessageService
+
+	db            *pg.DB
+	health        *health.Registry
+	healthHandler *handler.HealthHandler
+	purger        *service.MessagePurger
+	server        *http.Server
+}
+
+// New connects to Db described by config and builds the application around it
+func New(config properties.Config) (*App, error) {
+	db := repo.NewDb(config)
+	a, err := NewWithRepo(config, repo.NewMessageRepo(db, config.DbTimeout))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	a.db = db
+
+	a.health.Register("db", health.CheckerFunc(func(ctx context.Context) error {
+		return repo.PingDb(ctx, db)
+	}))
+	a.health.Register("migrations", health.CheckerFunc(func(ctx context.Context) error {
+		return repo.CheckMigrations(ctx, db)
+	}))
+	if err := metrics.RegisterDbPoolStats(db); err != nil {
+		log.Warn("Db pool statistics are not exposed: ", err)
+	}
+	return a, nil
+}
+
+// NewWithRepo builds the application around msgRepo, so that it can run on substitute storage
+func NewWithRepo(config properties.Config, msgRepo repo.MessageRepo) (*App, error) {
+	a := &App{
+		Config: config,
+		Router: mux.NewRouter(),
+		Service: &service.InstrumentedMessageService{
+			Next: &service.MessageServiceImpl{
+				MsgRepo:        msgRepo,
+				IdempotencyTTL: config.IdempotencyTTL,
+			},
+		},
+		health: health.NewRegistry(config.HealthCheckTimeout),
+		purger: &service.MessagePurger{
+			MsgRepo:             msgRepo,
+			Retention:           config.PurgeRetention,
+			Interval:            config.PurgeInterval,
+			IdempotencyInterval: config.IdempotencyPurgeInterval,
+			BatchSize:           config.PurgeBatchSize,
+		},
+	}
+
+	a.Router.Use(mid.Recoverer)
+	a.Router.Use(middleware.TracingMiddleware)
+	a.Router.Use(middleware.NewRequestParamsMiddleware(config.AdminKey))
+	a.Router.Use(middleware.MetricsMiddleware)
+	if config.RateLimitByIP != "" {
+		// applied before authentication, so that rejected requests are limited as well
+		limiter, err := ratelimit.NewClientLimiter(ratelimit.NewMemoryStore(), config.RateLimitByIP)
+		if err != nil {
+			return nil, err
+		}
+		a.Router.Use(middleware.NewRateLimitMiddleware(limiter, config.RateLimitTrustedProxies, unprotectedPaths...))
+	}
+	if config.AuthEnabled {
+		verifier, err := auth.NewVerifier(auth.Config{
+			HmacSecret:   config.AuthHmacSecret,
+			RsaPublicKey: config.AuthRsaPublicKey,
+			JwksFile:     config.AuthJwksFile,
+			Issuer:       config.AuthIssuer,
+			Audience:     config.AuthAudience,
+			AdminRole:    config.AuthAdminRole,
+		})
+		if err != nil {
+			return nil, err
+		}
+		a.Router.Use(middleware.NewAuthMiddleware(verifier, unprotectedPaths...))
+	} else {
+		log.Warn("Authentication is disabled, requests are served without bearer tokens")
+	}
+	if config.RateLimit != "" {
+		limiter, err := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), config.RateLimit, config.RateLimitRoutes)
+		if err != nil {
+			return nil, err
+		}
+		a.Router.Use(middleware.NewRateLimitMiddleware(limiter, config.RateLimitTrustedProxies, unprotectedPaths...))
+	}
+
+	handler.NewMessageHandler(a.Router, a.Service)
+	a.healthHandler = handler.HandleHealthRequest(a.Router, a.health)
+	a.Router.Handle("/metrics", metrics.Handler())
+	return a, nil
+}
+
+// Start starts purging of deleted messages and serving HTTP requests in background
+func (a *App) Start() {
+	a.startPurger()
+
+	port := strconv.Itoa(a.Config.Port)
+	a.server = &http.Server{
+		Addr:         ":" + port,
+		Handler:      a.Router,
+		ReadTimeout:  a.Config.HttpReadTimeout,
+		WriteTimeout: a.Config.HttpWriteTimeout,
+		IdleTimeout:  a.Config.HttpIdleTimeout,
+	}
+
+	log.Info("Starting server at port: ", port)
+	go func() {
+		err := a.server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+}
+
+func (a *App) startPurger() {
+	if a.purger.PurgesMessages() {
+		log.Info("Purging messages deleted more than ", a.purger.Retention, " ago, every ", a.purger.Interval)
+	} else {
+		log.Info("Purging of deleted messages is disabled")
+	}
+	if a.purger.PurgesIdempotencyRecords() {
+		log.Info("Purging expired idempotency keys every ", a.purger.IdempotencyInterval)
+	} else {
+		log.Info("Purging of expired idempotency keys is disabled")
+	}
+	a.purger.Start()
+}
+
+// Shutdown stops accepting new traffic, waits for in-flight requests and releases resources
+func (a *App) Shutdown() {
+	a.healthHandler.Drain()
+	log.Info("Readiness is switched off, draining for ", a.Config.ShutdownDrain)
+	time.Sleep(a.Config.ShutdownDrain)
+
+	if a.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), a.Config.ShutdownTimeout)
+		defer cancel()
+		if err := a.server.Shutdown(ctx); err != nil {
+			log.Error("Error shutting down server: ", err)
+		}
+	}
+
+	a.purger.Stop()
+	if a.db != nil {
+		if err := a.db.Close(); err != nil {
+			log.Error("Error closing Db: ", err)
+		}
+	}
+}
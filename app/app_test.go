@@ -0,0 +1,192 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/properties"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestApp(t *testing.T, config properties.Config, msgRepo repo.MessageRepo) *App {
+	a, err := NewWithRepo(config, msgRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestApp_ServesMessagesFromRepo(t *testing.T) {
+	// given:
+	msgRepo := &repo.MessageRepoMock{}
+	message := model.Message{Id: 1, Text: "MOCK_TEXT", Status: model.CREATED, Version: 2}
+	msgRepo.On("Get", mock.Anything, int64(1)).Once().Return(&message, nil)
+
+	a := newTestApp(t, properties.Config{}, msgRepo)
+	req := httptest.NewRequest("GET", properties.RootPath+"/message/1", nil)
+
+	// when:
+	w := httptest.NewRecorder()
+	a.Router.ServeHTTP(w, req)
+
+	// then:
+	response := model.MessageResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	assert.Equal(t, model.NewMessageResponse(&message), response)
+	msgRepo.AssertExpectations(t)
+}
+
+func TestApp_InstancesAreIndependent(t *testing.T) {
+	// given:
+	deleted := model.Message{Id: 1, Text: "MOCK_TEXT", Status: model.DELETED, Version: 2}
+	firstRepo := &repo.MessageRepoMock{}
+	firstRepo.On("Get", mock.Anything, int64(1)).Once().Return(&deleted, nil)
+	secondRepo := &repo.MessageRepoMock{}
+
+	first := newTestApp(t, properties.Config{AdminKey: "first_key"}, firstRepo)
+	second := newTestApp(t, properties.Config{AdminKey: "second_key"}, secondRepo)
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("GET", properties.RootPath+"/message/1?includeDeleted=true", nil)
+		req.Header.Set(model.HeaderKeyAdminKey, "first_key")
+		return req
+	}
+
+	// when:
+	firstResponse := httptest.NewRecorder()
+	first.Router.ServeHTTP(firstResponse, newRequest())
+	secondResponse := httptest.NewRecorder()
+	second.Router.ServeHTTP(secondResponse, newRequest())
+
+	// then:
+	assert.Equal(t, http.StatusOK, firstResponse.Code)
+	assert.Equal(t, http.StatusForbidden, secondResponse.Code)
+	firstRepo.AssertExpectations(t)
+	secondRepo.AssertExpectations(t)
+}
+
+func TestApp_Readiness(t *testing.T) {
+	// given:
+	a := newTestApp(t, properties.Config{}, &repo.MessageRepoMock{})
+	a.Shutdown()
+
+	// when:
+	w := httptest.NewRecorder()
+	a.Router.ServeHTTP(w, httptest.NewRequest("GET", "/readiness", nil))
+
+	// then:
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestApp_RequiresBearerToken(t *testing.T) {
+	// given:
+	msgRepo := &repo.MessageRepoMock{}
+	message := model.Message{Id: 1, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1, OwnerId: "user"}
+	msgRepo.On("Get", mock.Anything, int64(1)).Once().Return(&message, nil)
+
+	a := newTestApp(t, properties.Config{AuthEnabled: true, AuthHmacSecret: "secret"}, msgRepo)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	anonymous := httptest.NewRecorder()
+	a.Router.ServeHTTP(anonymous, httptest.NewRequest("GET", properties.RootPath+"/message/1", nil))
+	health := httptest.NewRecorder()
+	a.Router.ServeHTTP(health, httptest.NewRequest("GET", "/health", nil))
+	authenticated := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", properties.RootPath+"/message/1", nil)
+	req.Header.Set(model.HeaderKeyAuthorization, "Bearer "+token)
+	a.Router.ServeHTTP(authenticated, req)
+
+	// then:
+	assert.Equal(t, http.StatusUnauthorized, anonymous.Code)
+	assert.Equal(t, http.StatusOK, health.Code)
+	assert.Equal(t, http.StatusOK, authenticated.Code)
+	msgRepo.AssertExpectations(t)
+}
+
+func TestApp_AuthWithoutKeys(t *testing.T) {
+	// when:
+	a, err := NewWithRepo(properties.Config{AuthEnabled: true}, &repo.MessageRepoMock{})
+
+	// then:
+	assert.Nil(t, a)
+	assert.NotNil(t, err)
+}
+
+func TestApp_RateLimit(t *testing.T) {
+	// given:
+	msgRepo := &repo.MessageRepoMock{}
+	message := model.Message{Id: 1, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1}
+	msgRepo.On("Get", mock.Anything, int64(1)).Once().Return(&message, nil)
+
+	a := newTestApp(t, properties.Config{RateLimit: "1/m", RateLimitTrustedProxies: 1}, msgRepo)
+	newRequest := func(path string, ip string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set(model.HeaderKeyUserIP, ip)
+		return req
+	}
+
+	// when:
+	allowed := httptest.NewRecorder()
+	a.Router.ServeHTTP(allowed, newRequest(properties.RootPath+"/message/1", "10.0.0.1"))
+	limited := httptest.NewRecorder()
+	a.Router.ServeHTTP(limited, newRequest(properties.RootPath+"/message/1", "192.168.0.1, 10.0.0.1"))
+	health := httptest.NewRecorder()
+	a.Router.ServeHTTP(health, newRequest("/health", "10.0.0.1"))
+
+	// then:
+	assert.Equal(t, http.StatusOK, allowed.Code)
+	assert.Equal(t, "1", allowed.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", allowed.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", allowed.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "60", limited.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, health.Code)
+	msgRepo.AssertExpectations(t)
+}
+
+func TestApp_RateLimitByIPBeforeAuth(t *testing.T) {
+	// given:
+	a := newTestApp(t, properties.Config{
+		AuthEnabled:    true,
+		AuthHmacSecret: "secret",
+		RateLimitByIP:  "1/m",
+	}, &repo.MessageRepoMock{})
+
+	// when:
+	unauthorized := httptest.NewRecorder()
+	a.Router.ServeHTTP(unauthorized, httptest.NewRequest("GET", properties.RootPath+"/message/1", nil))
+	limited := httptest.NewRecorder()
+	a.Router.ServeHTTP(limited, httptest.NewRequest("DELETE", properties.RootPath+"/message/2", nil))
+
+	// then:
+	assert.Equal(t, http.StatusUnauthorized, unauthorized.Code)
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+}
+
+func TestApp_InvalidRateLimit(t *testing.T) {
+	// when:
+	a, err := NewWithRepo(properties.Config{RateLimit: "fast"}, &repo.MessageRepoMock{})
+
+	// then:
+	assert.Nil(t, a)
+	assert.NotNil(t, err)
+}
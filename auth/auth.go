@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Errors reported by Verifier, any other verification failure is ErrInvalidToken
+var (
+	ErrInvalidToken = errors.New("token is invalid")
+	ErrTokenExpired = errors.New("token has expired")
+)
+
+// Config describes keys accepted for token signatures, tokens are signed with HS256 using HmacSecret
+// or with RS256 using RsaPublicKey (PEM encoded) or one of the keys in JwksFile
+type Config struct {
+	HmacSecret   string
+	RsaPublicKey string
+	JwksFile     string
+	// Issuer and Audience are checked only when set
+	Issuer   string
+	Audience string
+	// AdminRole listed in roles claim of the token grants admin permissions, they are not granted when it is empty
+	AdminRole string
+}
+
+// Verifier validates JWT bearer tokens
+type Verifier struct {
+	hmacSecret []byte
+	// rsaKeys are looked up by key id, the key from config has an empty one
+	rsaKeys   map[string]*rsa.PublicKey
+	issuer    string
+	audience  string
+	adminRole string
+	parser    *jwt.Parser
+}
+
+// NewVerifier loads keys described by cfg, at least one of them must be configured
+func NewVerifier(cfg Config) (*Verifier, error) {
+	v := &Verifier{
+		rsaKeys:   map[string]*rsa.PublicKey{},
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+		adminRole: cfg.AdminRole,
+	}
+
+	var methods []string
+	if cfg.HmacSecret != "" {
+		v.hmacSecret = []byte(cfg.HmacSecret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RsaPublicKey != "" {
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(cfg.RsaPublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA public key: %w", err)
+		}
+		v.rsaKeys[""] = key
+	}
+	if cfg.JwksFile != "" {
+		keys, err := loadJwks(cfg.JwksFile)
+		if err != nil {
+			return nil, err
+		}
+		for kid, key := range keys {
+			v.rsaKeys[kid] = key
+		}
+	}
+	if len(v.rsaKeys) > 0 {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("no keys are configured to verify tokens")
+	}
+	// accepted algorithms are pinned, so that a public key is never used as HMAC secret
+	v.parser = jwt.NewParser(jwt.WithValidMethods(methods))
+	return v, nil
+}
+
+// Verify checks signature, expiry, issuer and audience of the token and returns its claims
+func (v *Verifier) Verify(token string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrTokenExpired
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidToken)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidToken)
+	}
+	if v.issuer != "" && !claims.VerifyIssuer(v.issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.audience != "" && !claims.VerifyAudience(v.audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return claims, nil
+}
+
+// IsAdmin reports whether verified claims grant admin role, roles claim is either a list or a single role
+func (v *Verifier) IsAdmin(claims jwt.MapClaims) bool {
+	if v.adminRole == "" {
+		return false
+	}
+	switch roles := claims["roles"].(type) {
+	case string:
+		return roles == v.adminRole
+	case []interface{}:
+		for _, role := range roles {
+			if role == v.adminRole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key returns key verifying signature of the token, RSA keys are chosen by the kid header
+func (v *Verifier) key(token *jwt.Token) (interface{}, error) {
+	if token.Method == jwt.SigningMethodHS256 {
+		return v.hmacSecret, nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := v.rsaKeys[kid]; ok {
+		return key, nil
+	}
+	if key, ok := v.rsaKeys[""]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
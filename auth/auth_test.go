@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const secret = "MOCK_SECRET"
+
+func claims(expiresAt time.Time) jwt.MapClaims {
+	return jwt.MapClaims{"sub": "MOCK_SUBJECT", "exp": expiresAt.Unix()}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func rsaKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestVerifier_HS256(t *testing.T) {
+	// given:
+	v, err := NewVerifier(Config{HmacSecret: secret})
+	assert.Nil(t, err)
+
+	// when:
+	result, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", claims(time.Now().Add(time.Minute)), []byte(secret)))
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, "MOCK_SUBJECT", result["sub"])
+}
+
+func TestVerifier_Expired(t *testing.T) {
+	// given:
+	v, _ := NewVerifier(Config{HmacSecret: secret})
+
+	// when:
+	_, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", claims(time.Now().Add(-time.Minute)), []byte(secret)))
+
+	// then:
+	assert.Equal(t, ErrTokenExpired, err)
+}
+
+func TestVerifier_Invalid(t *testing.T) {
+	v, _ := NewVerifier(Config{HmacSecret: secret, Issuer: "MOCK_ISSUER"})
+	valid := claims(time.Now().Add(time.Minute))
+	valid["iss"] = "MOCK_ISSUER"
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "MOCK_TOKEN"},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, "", valid, []byte("OTHER_SECRET"))},
+		{"unexpected algorithm", sign(t, jwt.SigningMethodHS512, "", valid, []byte(secret))},
+		{"no expiry", sign(t, jwt.SigningMethodHS256, "", jwt.MapClaims{"sub": "MOCK_SUBJECT", "iss": "MOCK_ISSUER"}, []byte(secret))},
+		{"no subject", sign(t, jwt.SigningMethodHS256, "", jwt.MapClaims{"exp": valid["exp"], "iss": "MOCK_ISSUER"}, []byte(secret))},
+		{"unexpected issuer", sign(t, jwt.SigningMethodHS256, "", claims(time.Now().Add(time.Minute)), []byte(secret))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when:
+			_, err := v.Verify(tt.token)
+
+			// then:
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestVerifier_RS256FromPEM(t *testing.T) {
+	// given:
+	key := rsaKey(t)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(Config{RsaPublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))})
+	assert.Nil(t, err)
+
+	// when:
+	_, err = v.Verify(sign(t, jwt.SigningMethodRS256, "", claims(time.Now().Add(time.Minute)), key))
+
+	// then:
+	assert.Nil(t, err)
+}
+
+func TestVerifier_RS256FromJwks(t *testing.T) {
+	// given:
+	first, second := rsaKey(t), rsaKey(t)
+	jwk := func(kid string, key *rsa.PrivateKey) string {
+		return fmt.Sprintf(`{"kty":"RSA","kid":%q,"use":"sig","alg":"RS256","n":%q,"e":%q}`, kid,
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	}
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	document := `{"keys":[` + jwk("first", first) + `,` + jwk("second", second) + `,{"kty":"EC","kid":"third"}]}`
+	if err := ioutil.WriteFile(file, []byte(document), 0600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(Config{JwksFile: file})
+	assert.Nil(t, err)
+
+	// when:
+	_, secondErr := v.Verify(sign(t, jwt.SigningMethodRS256, "second", claims(time.Now().Add(time.Minute)), second))
+	_, mismatchErr := v.Verify(sign(t, jwt.SigningMethodRS256, "first", claims(time.Now().Add(time.Minute)), second))
+	_, unknownErr := v.Verify(sign(t, jwt.SigningMethodRS256, "third", claims(time.Now().Add(time.Minute)), first))
+
+	// then:
+	assert.Nil(t, secondErr)
+	assert.ErrorIs(t, mismatchErr, ErrInvalidToken)
+	assert.ErrorIs(t, unknownErr, ErrInvalidToken)
+}
+
+func TestVerifier_RejectsPublicKeyAsHmacSecret(t *testing.T) {
+	// given:
+	key := rsaKey(t)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	v, _ := NewVerifier(Config{RsaPublicKey: string(publicKey)})
+
+	// when:
+	_, err := v.Verify(sign(t, jwt.SigningMethodHS256, "", claims(time.Now().Add(time.Minute)), publicKey))
+
+	// then:
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewVerifier_NoKeys(t *testing.T) {
+	// when:
+	_, err := NewVerifier(Config{})
+
+	// then:
+	assert.NotNil(t, err)
+}
+
+func TestVerifier_IsAdmin(t *testing.T) {
+	// given:
+	v, _ := NewVerifier(Config{HmacSecret: secret, AdminRole: "admin"})
+
+	// then:
+	assert.True(t, v.IsAdmin(jwt.MapClaims{"roles": []interface{}{"user", "admin"}}))
+	assert.True(t, v.IsAdmin(jwt.MapClaims{"roles": "admin"}))
+	assert.False(t, v.IsAdmin(jwt.MapClaims{"roles": []interface{}{"user"}}))
+	assert.False(t, v.IsAdmin(jwt.MapClaims{}))
+}
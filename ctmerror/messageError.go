@@ -1,15 +1,61 @@
 package ctmerror
 
 import (
+	"context"
 	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/model"
 	"github.com/go-pg/pg"
 	"net/http"
 )
 
+// StatusClientClosedRequest is the non-standard status of requests abandoned by the client
+const StatusClientClosedRequest = 499
+
 type MessageError struct {
 	errorCode string
 	err       error
 	httpCode  int
+	details   []FieldError
+}
+
+// FieldError describes a problem with a single field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// messages holds human readable descriptions of error codes
+var messages = map[string]string{
+	"error.go-example.message-not-found":       "Message with the given id does not exist",
+	"error.go-example.unexpected-error":        "Unexpected error occurred, please try again later",
+	"error.go-example.precondition-failed":     "Message was modified since the given version",
+	"error.go-example.forbidden":               "Not enough permissions to perform the operation",
+	"error.go-example.message-not-deleted":     "Message is not deleted",
+	"error.go-example.invalid-cursor":          "Cursor is malformed or expired",
+	"error.go-example.invalid-limit":           "Limit must be a positive integer",
+	"error.go-example.invalid-filter":          "Filter parameters are invalid",
+	"error.go-example.invalid-id":              "Message id must be an integer",
+	"error.go-example.invalid-etag":            "Entity tag is malformed",
+	"error.go-example.invalid-parameter":       "Query parameter is invalid",
+	"error.go-example.invalid-request-body":    "Request body is not a valid JSON document",
+	"error.go-example.validation-failed":       "Request does not satisfy validation rules",
+	"error.go-example.invalid-patch":           "Patch document is malformed or cannot be applied",
+	"error.go-example.unsupported-media-type":  "Content type of the request is not supported",
+	"error.go-example.revision-not-found":      "Message revision not found",
+	"error.go-example.invalid-revision":        "Revision number must be a positive integer",
+	"error.go-example.request-canceled":        "Request was canceled by the client",
+	"error.go-example.timeout":                 "Database did not respond in time",
+	"error.go-example.invalid-idempotency-key": "Idempotency key must be at most 255 characters long",
+	"error.go-example.idempotency-key-reused":  "Idempotency key was already used with a different request",
+	"error.go-example.invalid-batch-size":      "Batch must contain from 1 to 500 items",
+	"error.go-example.duplicate-batch-item":    "Message occurs more than once in the batch",
+	"error.go-example.batch-aborted":           "Item was not processed because another item of the batch failed",
+	"error.go-example.invalid-mode":            "Batch mode must be atomic or per-item",
+	"error.go-example.unauthorized":            "Bearer token is missing or invalid",
+	"error.go-example.token-expired":           "Bearer token has expired",
+	"error.go-example.access-denied":           "Message belongs to another user",
+	"error.go-example.too-many-requests":       "Too many requests, please retry later",
 }
 
 // Error() func indicates that MessageError implements error interface
@@ -21,10 +67,34 @@ func (e MessageError) HttpCode() int {
 	return e.httpCode
 }
 
-func (e MessageError) BaseError() error  {
+func (e MessageError) BaseError() error {
+	return e.err
+}
+
+// Unwrap exposes the underlying error to errors.Is and errors.As
+func (e MessageError) Unwrap() error {
 	return e.err
 }
 
+// Message returns human readable description of the error code
+func (e MessageError) Message() string {
+	if message, ok := messages[e.errorCode]; ok {
+		return message
+	}
+	return http.StatusText(e.httpCode)
+}
+
+// Details returns field level errors, if any
+func (e MessageError) Details() []FieldError {
+	return e.details
+}
+
+// WithDetails attaches field level errors to the error
+func (e *MessageError) WithDetails(details ...FieldError) *MessageError {
+	e.details = append(e.details, details...)
+	return e
+}
+
 func NewMessageError(repoError error) *MessageError {
 	var msgError MessageError
 
@@ -34,6 +104,24 @@ func NewMessageError(repoError error) *MessageError {
 			err:       repoError,
 			httpCode:  http.StatusNotFound,
 		}
+	} else if errors.Is(repoError, model.ErrVersionConflict) {
+		msgError = MessageError{
+			errorCode: "error.go-example.precondition-failed",
+			err:       repoError,
+			httpCode:  http.StatusPreconditionFailed,
+		}
+	} else if errors.Is(repoError, context.Canceled) {
+		msgError = MessageError{
+			errorCode: "error.go-example.request-canceled",
+			err:       repoError,
+			httpCode:  StatusClientClosedRequest,
+		}
+	} else if errors.Is(repoError, context.DeadlineExceeded) {
+		msgError = MessageError{
+			errorCode: "error.go-example.timeout",
+			err:       repoError,
+			httpCode:  http.StatusGatewayTimeout,
+		}
 	} else {
 		msgError = MessageError{
 			errorCode: "error.go-example.unexpected-error",
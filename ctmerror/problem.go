@@ -0,0 +1,43 @@
+package ctmerror
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// Problem is RFC 7807 representation of MessageError
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	RequestId string       `json:"requestId,omitempty"`
+	Timestamp time.Time    `json:"timestamp"`
+	Details   []FieldError `json:"details,omitempty"`
+}
+
+// NewProblem builds problem from err, errors other than MessageError are reported as unexpected
+func NewProblem(err error, instance string, requestId string) Problem {
+	var msgError *MessageError
+	if !errors.As(err, &msgError) {
+		msgError = NewMessageError(err)
+	}
+
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(msgError.HttpCode()),
+		Status:    msgError.HttpCode(),
+		Instance:  instance,
+		Code:      msgError.Error(),
+		Message:   msgError.Message(),
+		RequestId: requestId,
+		Timestamp: time.Now().UTC(),
+		Details:   msgError.Details(),
+	}
+}
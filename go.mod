@@ -4,20 +4,26 @@ go 1.13
 
 require (
 	github.com/alexflint/go-arg v1.3.0
+	github.com/evanphx/json-patch v4.9.0+incompatible
 	github.com/go-chi/chi v4.0.4+incompatible
 	github.com/go-pg/pg v8.0.6+incompatible
-	github.com/golang/protobuf v1.3.3 // indirect
-	github.com/google/uuid v1.1.1
+	github.com/go-playground/validator/v10 v10.4.1
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/google/uuid v1.1.2
 	github.com/gorilla/mux v1.7.4
 	github.com/jessevdk/go-flags v1.4.0
 	github.com/jinzhu/inflection v1.0.0 // indirect
 	github.com/joho/godotenv v1.3.0
 	github.com/lib/pq v1.3.0
+	github.com/prometheus/client_golang v1.11.1
 	github.com/rubenv/sql-migrate v0.0.0-20200402132117-435005d389bc
-	github.com/sirupsen/logrus v1.5.0
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d // indirect
-	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e // indirect
-	gopkg.in/yaml.v2 v2.2.8 // indirect
+	github.com/sirupsen/logrus v1.6.0
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/contrib/propagators/b3 v1.0.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.1
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	mellium.im/sasl v0.2.1 // indirect
 )
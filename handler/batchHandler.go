@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/service"
+	"github.com/FatimaBabayeva/ms-go-example/validation"
+	"net/http"
+)
+
+// Batch modes, see service.MessageService.SaveMessages
+const (
+	batchModeAtomic  = "atomic"
+	batchModePerItem = "per-item"
+)
+
+// batchItemResponse is the API representation of the outcome of a single batch item,
+// failed items carry the problem they failed with
+type batchItemResponse struct {
+	Status  int                    `json:"status"`
+	Message *model.MessageResponse `json:"message,omitempty"`
+	Error   *ctmerror.Problem      `json:"error,omitempty"`
+}
+
+// batchResponse lists outcomes of batch items in the order of the request
+type batchResponse struct {
+	Items []batchItemResponse `json:"items"`
+}
+
+// decodeItemFunc decodes and validates a single batch item into the message it describes
+type decodeItemFunc func(item []byte) (model.Message, *ctmerror.MessageError)
+
+// processBatchFunc is a batch operation of service.MessageService
+type processBatchFunc func(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error)
+
+func (h *messageHandler) saveMessages(w http.ResponseWriter, r *http.Request) {
+	serveBatch(w, r, decodeCreateItem, h.service.SaveMessages, http.StatusCreated)
+}
+
+func (h *messageHandler) updateMessages(w http.ResponseWriter, r *http.Request) {
+	serveBatch(w, r, decodeUpdateItem, h.service.UpdateMessages, http.StatusOK)
+}
+
+func (h *messageHandler) deleteMessages(w http.ResponseWriter, r *http.Request) {
+	serveBatch(w, r, decodeDeleteItem, h.service.DeleteMessages, http.StatusNoContent)
+}
+
+func decodeCreateItem(item []byte) (model.Message, *ctmerror.MessageError) {
+	var request model.CreateMessageRequest
+	if msgErr := validation.Decode(bytes.NewReader(item), &request); msgErr != nil {
+		return model.Message{}, msgErr
+	}
+	return request.ToMessage(), nil
+}
+
+func decodeUpdateItem(item []byte) (model.Message, *ctmerror.MessageError) {
+	var request model.UpdateMessageBatchItem
+	if msgErr := validation.Decode(bytes.NewReader(item), &request); msgErr != nil {
+		return model.Message{}, msgErr
+	}
+	return request.ToMessage(), nil
+}
+
+func decodeDeleteItem(item []byte) (model.Message, *ctmerror.MessageError) {
+	var request model.DeleteMessageBatchItem
+	if msgErr := validation.Decode(bytes.NewReader(item), &request); msgErr != nil {
+		return model.Message{}, msgErr
+	}
+	return request.ToMessage(), nil
+}
+
+// serveBatch decodes JSON array of items and processes the valid ones, answering with 207 Multi-Status
+// and the outcome of every item. Succeeded items get status, the failed ones the status of their error.
+// In atomic mode an invalid item aborts the whole batch.
+func serveBatch(w http.ResponseWriter, r *http.Request, decode decodeItemFunc, process processBatchFunc, status int) {
+	perItem, err := parseBatchMode(r)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-mode", err)
+		return
+	}
+
+	var items []json.RawMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&items); err != nil {
+		badRequest(w, r, validation.InvalidBodyErrorCode, err)
+		return
+	}
+	if len(items) == 0 || len(items) > service.MaxBatchSize {
+		badRequest(w, r, "error.go-example.invalid-batch-size", nil)
+		return
+	}
+
+	results := make([]batchItemResponse, len(items))
+	messages := make([]model.Message, 0, len(items))
+	// positions of decoded messages in items
+	positions := make([]int, 0, len(items))
+	for i, item := range items {
+		m, msgErr := decode(item)
+		if msgErr != nil {
+			results[i] = newBatchItemResponse(r, model.BatchResult{Err: msgErr}, status)
+			continue
+		}
+		messages = append(messages, m)
+		positions = append(positions, i)
+	}
+
+	switch {
+	case len(messages) == 0:
+	case !perItem && len(messages) < len(items):
+		for _, i := range positions {
+			results[i] = newBatchItemResponse(r, model.BatchResult{Err: service.ErrBatchAborted}, status)
+		}
+	default:
+		batchResults, err := process(r.Context(), messages, perItem)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		for k, result := range batchResults {
+			results[positions[k]] = newBatchItemResponse(r, result, status)
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+	json.NewEncoder(w).Encode(batchResponse{Items: results})
+}
+
+func parseBatchMode(r *http.Request) (bool, error) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", batchModeAtomic:
+		return false, nil
+	case batchModePerItem:
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown batch mode %q", mode)
+	}
+}
+
+func newBatchItemResponse(r *http.Request, result model.BatchResult, status int) batchItemResponse {
+	if result.Err != nil {
+		problem := ctmerror.NewProblem(result.Err, r.URL.Path, requestId(r))
+		return batchItemResponse{Status: problem.Status, Error: &problem}
+	}
+
+	res := batchItemResponse{Status: status}
+	if result.Message != nil && status != http.StatusNoContent {
+		message := model.NewMessageResponse(result.Message)
+		res.Message = &message
+	}
+	return res
+}
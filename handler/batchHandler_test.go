@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/properties"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// batchStatuses returns statuses of batch items written to w
+func batchStatuses(t *testing.T, w *httptest.ResponseRecorder) []int {
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	response := batchResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	statuses := make([]int, 0, len(response.Items))
+	for _, item := range response.Items {
+		statuses = append(statuses, item.Status)
+	}
+	return statuses
+}
+
+func TestSaveMessages_Ok(t *testing.T) {
+	// given:
+	messages := []model.Message{{Text: "FIRST"}, {Text: "SECOND"}}
+	results := []model.BatchResult{
+		{Message: &model.Message{Id: 1, Text: "FIRST"}},
+		{Message: &model.Message{Id: 2, Text: "SECOND"}},
+	}
+	mockService.On("SaveMessages", mock.Anything, messages, false).Once().Return(results, nil)
+
+	req, err := http.NewRequest("POST", properties.RootPath+"/message/batch",
+		strings.NewReader(`[{"text": "FIRST"}, {"text": "SECOND"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.saveMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	response := batchResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Len(t, response.Items, 2)
+	assert.Equal(t, http.StatusCreated, response.Items[1].Status)
+	assert.Equal(t, int64(2), response.Items[1].Message.Id)
+	mockService.AssertExpectations(t)
+}
+
+func TestSaveMessages_InvalidItemAbortsBatch(t *testing.T) {
+	// given:
+	req, err := http.NewRequest("POST", properties.RootPath+"/message/batch",
+		strings.NewReader(`[{"text": ""}, {"text": "SECOND"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.saveMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, []int{http.StatusUnprocessableEntity, http.StatusFailedDependency}, batchStatuses(t, w))
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateMessages_PerItem(t *testing.T) {
+	// given:
+	messages := []model.Message{{Id: 2, Text: "SECOND", Version: 3}}
+	results := []model.BatchResult{{Err: notFoundErr}}
+	mockService.On("UpdateMessages", mock.Anything, messages, true).Once().Return(results, nil)
+
+	req, err := http.NewRequest("PUT", properties.RootPath+"/message/batch?mode=per-item",
+		strings.NewReader(`[{"text": "FIRST"}, {"id": 2, "text": "SECOND", "version": 3}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.updateMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, []int{http.StatusUnprocessableEntity, http.StatusNotFound}, batchStatuses(t, w))
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteMessages_VersionRequired(t *testing.T) {
+	// given:
+	req, err := http.NewRequest("DELETE", properties.RootPath+"/message/batch",
+		strings.NewReader(`[{"id": 1}, {"id": 2, "version": 3}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.deleteMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, []int{http.StatusUnprocessableEntity, http.StatusFailedDependency}, batchStatuses(t, w))
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteMessages_Route(t *testing.T) {
+	// given:
+	messages := []model.Message{{Id: 1, Version: 2}}
+	mockService.On("DeleteMessages", mock.Anything, messages, false).Once().Return([]model.BatchResult{{}}, nil)
+
+	router := NewMessageHandler(mux.NewRouter(), &mockService)
+	req, err := http.NewRequest("DELETE", properties.RootPath+"/message/batch", strings.NewReader(`[{"id": 1, "version": 2}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, []int{http.StatusNoContent}, batchStatuses(t, w))
+	mockService.AssertExpectations(t)
+}
+
+func TestBatch_BadRequest(t *testing.T) {
+	cases := []struct {
+		name string
+		path string
+		body string
+		code string
+	}{
+		{"empty batch", "/message/batch", `[]`, "error.go-example.invalid-batch-size"},
+		{"not an array", "/message/batch", `{"id": 1}`, "error.go-example.invalid-request-body"},
+		{"unknown mode", "/message/batch?mode=some", `[{"id": 1}]`, "error.go-example.invalid-mode"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// given:
+			req, err := http.NewRequest("DELETE", properties.RootPath+c.path, strings.NewReader(c.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// when:
+			handler := http.HandlerFunc(handler.deleteMessages)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			// then:
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, c.code, problemCode(t, w))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// writeError answers with RFC 7807 problem document built from err
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := ctmerror.NewProblem(err, r.URL.Path, requestId(r))
+
+	w.Header().Set("Content-Type", ctmerror.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// badRequest is a shortcut for client errors found while parsing the request
+func badRequest(w http.ResponseWriter, r *http.Request, errorCode string, err error) {
+	writeError(w, r, ctmerror.NewMessageErrorBuilder(errorCode, err, http.StatusBadRequest))
+}
+
+// requestId returns id of the request assigned by RequestParamsMiddleware
+func requestId(r *http.Request) string {
+	logger, ok := r.Context().Value(model.ContextLogger).(*log.Entry)
+	if !ok {
+		return ""
+	}
+	id, _ := logger.Data[model.LoggerKeyRequestID].(string)
+	return id
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var errInvalidETag = errors.New("malformed entity tag")
+
+// noVersion is passed as the expected version when no tag of If-Match can match, no message has it
+const noVersion int64 = -1
+
+// setETag exposes message version to the client as an entity tag
+func setETag(w http.ResponseWriter, m *model.Message) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(m.Version, 10)+`"`)
+}
+
+// parseETag returns message version from strong entity tag, "*" matches any version and is returned as 0
+func parseETag(tag string) (int64, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "*" {
+		return 0, nil
+	}
+
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errInvalidETag
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version <= 0 {
+		return 0, errInvalidETag
+	}
+	return version, nil
+}
+
+// ifMatchVersion reads the expected version of the message from If-Match header, 0 means no precondition.
+// Weak tags never match, as If-Match requires strong comparison. Of several tags the one matching
+// the current version of the message is expected, the service then checks it has not changed since.
+func (h *messageHandler) ifMatchVersion(r *http.Request, id int64) (int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		weak := strings.HasPrefix(tag, "W/")
+		version, err := parseETag(strings.TrimPrefix(tag, "W/"))
+		if err != nil || (weak && version == 0) {
+			return 0, errInvalidETag
+		}
+		if version == 0 {
+			return 0, nil
+		}
+		if !weak {
+			versions = append(versions, version)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return noVersion, nil
+	case 1:
+		return versions[0], nil
+	}
+	// failure to get the message is reported by the operation made with any of the versions
+	if current, err := h.service.GetMessageById(r.Context(), id, false); err == nil {
+		for _, version := range versions {
+			if version == current.Version {
+				return version, nil
+			}
+		}
+	}
+	return versions[0], nil
+}
+
+// ifNoneMatch reports whether any tag of If-None-Match header matches the message version,
+// tags are compared weakly
+func ifNoneMatch(r *http.Request, m *model.Message) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		version, err := parseETag(strings.TrimPrefix(strings.TrimSpace(tag), "W/"))
+		if err == nil && (version == 0 || version == m.Version) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,20 +1,58 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/health"
 	"github.com/gorilla/mux"
 	"net/http"
+	"sync/atomic"
 )
 
-type healthHandler struct{}
+// HealthHandler serves kubernetes health and readiness checks
+type HealthHandler struct {
+	registry *health.Registry
+	draining int32
+}
+
+var errDraining = errors.New("application is shutting down")
 
 // HandleHealthRequest is for handling requests of kubernetes health and readiness checks
-func HandleHealthRequest(router *mux.Router) {
-	h := &healthHandler{}
+func HandleHealthRequest(router *mux.Router, registry *health.Registry) *HealthHandler {
+	h := &HealthHandler{registry: registry}
+	registry.Register("server", health.CheckerFunc(h.checkDraining))
 
-	router.HandleFunc("/readiness", h.Health)
+	router.HandleFunc("/readiness", h.Readiness)
 	router.HandleFunc("/health", h.Health)
+	return h
 }
 
-// Health is a function that stands behind the health/readiness endpoint call
-func (*healthHandler) Health(w http.ResponseWriter, r *http.Request) {
+// Drain makes readiness check fail, so that no new traffic is routed to the application during shutdown
+func (h *HealthHandler) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+func (h *HealthHandler) checkDraining(ctx context.Context) error {
+	if atomic.LoadInt32(&h.draining) == 1 {
+		return errDraining
+	}
+	return nil
+}
+
+// Health is a function that stands behind the liveness endpoint call, it does not check any dependencies
+func (*HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
+}
+
+// Readiness is a function that stands behind the readiness endpoint call, it reports every registered check
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := h.registry.Check(r.Context())
+
+	w.Header().Add("Content-Type", "application/json")
+	if report.Status == health.StatusUp {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
 }
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/health"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newHealthHandler(dbErr error) *HealthHandler {
+	registry := health.NewRegistry(time.Second)
+	registry.Register("db", health.CheckerFunc(func(ctx context.Context) error { return dbErr }))
+	return HandleHealthRequest(mux.NewRouter(), registry)
+}
+
+func TestReadiness_Ok(t *testing.T) {
+	// given:
+	h := newHealthHandler(nil)
+	req, err := http.NewRequest("GET", "/readiness", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	w := httptest.NewRecorder()
+	http.HandlerFunc(h.Readiness).ServeHTTP(w, req)
+
+	// then:
+	report := health.Report{}
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Equal(t, health.StatusUp, report.Components["db"].Status)
+	assert.Equal(t, health.StatusUp, report.Components["server"].Status)
+}
+
+func TestReadiness_DbDown(t *testing.T) {
+	// given:
+	h := newHealthHandler(errors.New("connection refused"))
+	req, err := http.NewRequest("GET", "/readiness", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	w := httptest.NewRecorder()
+	http.HandlerFunc(h.Readiness).ServeHTTP(w, req)
+
+	// then:
+	report := health.Report{}
+	err = json.Unmarshal(w.Body.Bytes(), &report)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, "connection refused", report.Components["db"].Error)
+}
+
+func TestReadiness_Draining(t *testing.T) {
+	// given:
+	h := newHealthHandler(nil)
+	h.Drain()
+	req, err := http.NewRequest("GET", "/readiness", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	w := httptest.NewRecorder()
+	http.HandlerFunc(h.Readiness).ServeHTTP(w, req)
+	hw := httptest.NewRecorder()
+	http.HandlerFunc(h.Health).ServeHTTP(hw, req)
+
+	// then:
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, http.StatusOK, hw.Code)
+}
@@ -2,118 +2,362 @@ package handler
 
 import (
 	"encoding/json"
-	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
-	"github.com/FatimaBabayeva/ms-go-example/middleware"
+	"fmt"
 	"github.com/FatimaBabayeva/ms-go-example/model"
 	"github.com/FatimaBabayeva/ms-go-example/properties"
-	"github.com/FatimaBabayeva/ms-go-example/repo"
 	"github.com/FatimaBabayeva/ms-go-example/service"
-	mid "github.com/go-chi/chi/middleware"
 	"github.com/gorilla/mux"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type messageHandler struct {
 	service service.MessageService
 }
 
-var messageService = service.MessageServiceImpl{
-	MsgRepo: &repo.MessageRepoImpl{},
-}
-
-// NewMessageHandler returns new message handler with predefined configuration
-func NewMessageHandler(router *mux.Router) *mux.Router {
-	router.Use(mid.Recoverer)
-	router.Use(middleware.RequestParamsMiddleware)
-
-	h := &messageHandler{service: &messageService}
+// NewMessageHandler registers message endpoints backed by messageService on router
+func NewMessageHandler(router *mux.Router, messageService service.MessageService) *mux.Router {
+	h := &messageHandler{service: messageService}
 
 	router.HandleFunc(properties.RootPath+"/message", h.saveMessage).Methods("POST")
+	router.HandleFunc(properties.RootPath+"/message", h.listMessages).Methods("GET")
+	// batch routes go first, so that "batch" is not taken for a message id
+	router.HandleFunc(properties.RootPath+"/message/batch", h.saveMessages).Methods("POST")
+	router.HandleFunc(properties.RootPath+"/message/batch", h.updateMessages).Methods("PUT")
+	router.HandleFunc(properties.RootPath+"/message/batch", h.deleteMessages).Methods("DELETE")
 	router.HandleFunc(properties.RootPath+"/message/{id}", h.getMessage).Methods("GET")
 	router.HandleFunc(properties.RootPath+"/message/{id}", h.editMessage).Methods("PUT")
+	router.HandleFunc(properties.RootPath+"/message/{id}", h.patchMessage).Methods("PATCH")
 	router.HandleFunc(properties.RootPath+"/message/{id}", h.deleteMessage).Methods("DELETE")
+	router.HandleFunc(properties.RootPath+"/message/{id}/restore", h.restoreMessage).Methods("POST")
+	router.HandleFunc(properties.RootPath+"/message/{id}/revisions", h.listRevisions).Methods("GET")
+	router.HandleFunc(properties.RootPath+"/message/{id}/revisions/{revision}", h.getRevision).Methods("GET")
+	router.HandleFunc(properties.RootPath+"/message/{id}/revisions/{revision}/revert", h.revertMessage).Methods("POST")
 	return router
 }
 
 func (h *messageHandler) saveMessage(w http.ResponseWriter, r *http.Request) {
-	var m model.Message
-	err := json.NewDecoder(r.Body).Decode(&m)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var request model.CreateMessageRequest
+	if msgErr := decodeBody(w, r, &request); msgErr != nil {
+		writeError(w, r, msgErr)
 		return
 	}
 
-	result, err := h.service.SaveMessage(r.Context(), m)
+	idempotencyKey := r.Header.Get(model.HeaderKeyIdempotencyKey)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		badRequest(w, r, "error.go-example.invalid-idempotency-key", nil)
+		return
+	}
+
+	result, err := h.service.SaveMessage(r.Context(), request.ToMessage(), idempotencyKey)
 	if err != nil {
-		http.Error(w, err.Error(), err.(*ctmerror.MessageError).HttpCode())
+		writeError(w, r, err)
 		return
 	}
 
+	setETag(w, result)
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(model.NewMessageResponse(result))
+}
+
+func (h *messageHandler) listMessages(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 {
+			badRequest(w, r, "error.go-example.invalid-limit", err)
+			return
+		}
+	}
+
+	filter, err := parseMessageFilter(query)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-filter", err)
+		return
+	}
+
+	result, err := h.service.ListMessages(r.Context(), filter, query.Get("cursor"), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.NewMessagePageResponse(result))
+}
+
+// parseMessageFilter reads listing criteria from query parameters, timestamps are expected in RFC 3339
+func parseMessageFilter(query url.Values) (model.MessageFilter, error) {
+	filter := model.MessageFilter{
+		Status: model.MessageStatus(query.Get("status")),
+		Query:  strings.TrimSpace(query.Get("q")),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return filter, fmt.Errorf("unknown status %q", filter.Status)
+	}
+
+	includeDeleted, err := parseIncludeDeleted(query)
+	if err != nil {
+		return filter, err
+	}
+	filter.IncludeDeleted = includeDeleted
+
+	timeParams := map[string]**time.Time{
+		"createdFrom": &filter.CreatedFrom,
+		"createdTo":   &filter.CreatedTo,
+		"updatedFrom": &filter.UpdatedFrom,
+		"updatedTo":   &filter.UpdatedTo,
+	}
+	for param, target := range timeParams {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, err
+		}
+		*target = &t
+	}
+	return filter, nil
+}
+
+func parseIncludeDeleted(query url.Values) (bool, error) {
+	value := query.Get("includeDeleted")
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
 }
 
 func (h *messageHandler) getMessage(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, r, "error.go-example.invalid-id", err)
 		return
 	}
 
-	result, err := h.service.GetMessageById(r.Context(), id)
+	includeDeleted, err := parseIncludeDeleted(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), err.(*ctmerror.MessageError).HttpCode())
+		badRequest(w, r, "error.go-example.invalid-parameter", err)
+		return
+	}
+
+	result, err := h.service.GetMessageById(r.Context(), id, includeDeleted)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, result)
+	if ifNoneMatch(r, result) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(model.NewMessageResponse(result))
 }
 
 func (h *messageHandler) editMessage(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, r, "error.go-example.invalid-id", err)
 		return
 	}
 
-	var m model.Message
-	err = json.NewDecoder(r.Body).Decode(&m)
+	version, err := h.ifMatchVersion(r, id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, r, "error.go-example.invalid-etag", err)
+		return
+	}
+
+	var request model.UpdateMessageRequest
+	if msgErr := decodeBody(w, r, &request); msgErr != nil {
+		writeError(w, r, msgErr)
 		return
 	}
 
-	result, err := h.service.UpdateMessageById(r.Context(), id, m)
+	result, err := h.service.UpdateMessageById(r.Context(), id, request.ToMessage(), version)
 	if err != nil {
-		http.Error(w, err.Error(), err.(*ctmerror.MessageError).HttpCode())
+		writeError(w, r, err)
 		return
 	}
 
+	setETag(w, result)
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(result)
+	json.NewEncoder(w).Encode(model.NewMessageResponse(result))
+}
+
+func (h *messageHandler) patchMessage(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-id", err)
+		return
+	}
+
+	version, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-etag", err)
+		return
+	}
+
+	patch, msgErr := readPatch(w, r)
+	if msgErr != nil {
+		writeError(w, r, msgErr)
+		return
+	}
+
+	result, err := h.service.PatchMessageById(r.Context(), id, patch, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, result)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.NewMessageResponse(result))
 }
 
 func (h *messageHandler) deleteMessage(w http.ResponseWriter, r *http.Request) {
 	idStr := mux.Vars(r)["id"]
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, r, "error.go-example.invalid-id", err)
+		return
+	}
+
+	version, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-etag", err)
 		return
 	}
 
-	err = h.service.DeleteMessageById(r.Context(), id)
+	err = h.service.DeleteMessageById(r.Context(), id, version)
 	if err != nil {
-		http.Error(w, err.Error(), err.(*ctmerror.MessageError).HttpCode())
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *messageHandler) restoreMessage(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-id", err)
+		return
+	}
+
+	result, err := h.service.RestoreMessageById(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, result)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.NewMessageResponse(result))
+}
+
+func (h *messageHandler) listRevisions(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-id", err)
+		return
+	}
+
+	result, err := h.service.ListMessageRevisions(r.Context(), id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.NewMessageRevisionListResponse(result))
+}
+
+func (h *messageHandler) getRevision(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-id", err)
+		return
+	}
+
+	revision, err := parseRevision(mux.Vars(r)["revision"])
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-revision", err)
+		return
+	}
+
+	result, err := h.service.GetMessageRevision(r.Context(), id, revision)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.NewMessageRevisionResponse(result))
+}
+
+func (h *messageHandler) revertMessage(w http.ResponseWriter, r *http.Request) {
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-id", err)
+		return
+	}
+
+	revision, err := parseRevision(mux.Vars(r)["revision"])
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-revision", err)
+		return
+	}
+
+	version, err := h.ifMatchVersion(r, id)
+	if err != nil {
+		badRequest(w, r, "error.go-example.invalid-etag", err)
+		return
+	}
+
+	result, err := h.service.RevertMessageById(r.Context(), id, revision, version)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	setETag(w, result)
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(model.NewMessageResponse(result))
+}
+
+// parseRevision reads revision number from path, revisions start from 1
+func parseRevision(value string) (int64, error) {
+	revision, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && revision < 1 {
+		err = fmt.Errorf("revision %d is out of range", revision)
+	}
+	return revision, err
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
 	"github.com/FatimaBabayeva/ms-go-example/model"
@@ -9,12 +10,14 @@ import (
 	"github.com/FatimaBabayeva/ms-go-example/service"
 	"github.com/go-pg/pg"
 	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 var (
@@ -35,6 +38,19 @@ var (
 	}
 )
 
+// problemCode returns error code of the problem document written to w
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	problem := ctmerror.Problem{}
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, w.Code, problem.Status)
+	return problem.Code
+}
+
 func TestSaveMessage_Ok(t *testing.T) {
 	// given:
 	message := model.Message{Text: "MOCK_TEXT"}
@@ -43,9 +59,9 @@ func TestSaveMessage_Ok(t *testing.T) {
 		Text:   "MOCK_TEXT",
 		Status: "CREATED",
 	}
-	mockService.On("SaveMessage", mock.Anything, message).Once().Return(&savedMessage, nil)
+	mockService.On("SaveMessage", mock.Anything, message, "").Once().Return(&savedMessage, nil)
 
-	requestJson, _ := json.Marshal(message)
+	requestJson, _ := json.Marshal(model.CreateMessageRequest{Text: message.Text})
 	req, err := http.NewRequest("POST", properties.RootPath+"/message", bytes.NewBuffer(requestJson))
 	if err != nil {
 		t.Fatal(err)
@@ -57,7 +73,7 @@ func TestSaveMessage_Ok(t *testing.T) {
 	handler.ServeHTTP(w, req)
 
 	// then:
-	result := model.Message{}
+	result := model.MessageResponse{}
 	err = json.Unmarshal(w.Body.Bytes(), &result)
 	if err != nil {
 		t.Fatal(err)
@@ -65,10 +81,50 @@ func TestSaveMessage_Ok(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, w.Code)
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
-	assert.Equal(t, savedMessage, result)
+	assert.Equal(t, model.NewMessageResponse(&savedMessage), result)
 	mockService.AssertExpectations(t)
 }
 
+func TestSaveMessage_IdempotencyKey(t *testing.T) {
+	// given:
+	message := model.Message{Text: "MOCK_TEXT"}
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: "CREATED"}
+	mockService.On("SaveMessage", mock.Anything, message, "MOCK_KEY").Once().Return(&savedMessage, nil)
+
+	req, err := http.NewRequest("POST", properties.RootPath+"/message", strings.NewReader(`{"text": "MOCK_TEXT"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(model.HeaderKeyIdempotencyKey, "MOCK_KEY")
+
+	// when:
+	handler := http.HandlerFunc(handler.saveMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestSaveMessage_InvalidIdempotencyKey(t *testing.T) {
+	// given:
+	req, err := http.NewRequest("POST", properties.RootPath+"/message", strings.NewReader(`{"text": "MOCK_TEXT"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(model.HeaderKeyIdempotencyKey, strings.Repeat("k", 256))
+
+	// when:
+	handler := http.HandlerFunc(handler.saveMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "error.go-example.invalid-idempotency-key", problemCode(t, w))
+}
+
 func TestGetMessage_Ok(t *testing.T) {
 	// given:
 	message := model.Message{
@@ -76,7 +132,7 @@ func TestGetMessage_Ok(t *testing.T) {
 		Text:   "MOCK_TEXT",
 		Status: "CREATED",
 	}
-	mockService.On("GetMessageById", mock.Anything, id).Once().Return(&message, nil)
+	mockService.On("GetMessageById", mock.Anything, id, false).Once().Return(&message, nil)
 
 	req, err := http.NewRequest("GET", properties.RootPath+"/message/{id}", nil)
 	if err != nil {
@@ -93,7 +149,7 @@ func TestGetMessage_Ok(t *testing.T) {
 	handler.ServeHTTP(w, req)
 
 	// then:
-	result := model.Message{}
+	result := model.MessageResponse{}
 	err = json.Unmarshal(w.Body.Bytes(), &result)
 	if err != nil {
 		t.Fatal(err)
@@ -101,7 +157,35 @@ func TestGetMessage_Ok(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
-	assert.Equal(t, message, result)
+	assert.Equal(t, model.NewMessageResponse(&message), result)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetMessage_IncludeDeleted(t *testing.T) {
+	// given:
+	message := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "DELETED",
+	}
+	mockService.On("GetMessageById", mock.Anything, id, true).Once().Return(&message, nil)
+
+	req, err := http.NewRequest("GET", properties.RootPath+"/message/{id}?includeDeleted=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.getMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusOK, w.Code)
 	mockService.AssertExpectations(t)
 }
 
@@ -113,9 +197,9 @@ func TestEditMessage_Ok(t *testing.T) {
 		Text:   "UPDATED",
 		Status: "CREATED",
 	}
-	mockService.On("UpdateMessageById", mock.Anything, id, message).Once().Return(&updatedMessage, nil)
+	mockService.On("UpdateMessageById", mock.Anything, id, message, int64(0)).Once().Return(&updatedMessage, nil)
 
-	requestJson, _ := json.Marshal(message)
+	requestJson, _ := json.Marshal(model.UpdateMessageRequest{Text: message.Text})
 	req, err := http.NewRequest("PUT", properties.RootPath+"/message/{id}", bytes.NewBuffer(requestJson))
 	if err != nil {
 		t.Fatal(err)
@@ -131,7 +215,7 @@ func TestEditMessage_Ok(t *testing.T) {
 	handler.ServeHTTP(w, req)
 
 	// then:
-	result := model.Message{}
+	result := model.MessageResponse{}
 	err = json.Unmarshal(w.Body.Bytes(), &result)
 	if err != nil {
 		t.Fatal(err)
@@ -139,13 +223,13 @@ func TestEditMessage_Ok(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
-	assert.Equal(t, updatedMessage, result)
+	assert.Equal(t, model.NewMessageResponse(&updatedMessage), result)
 	mockService.AssertExpectations(t)
 }
 
 func TestDeleteMessage_Ok(t *testing.T) {
 	// given:
-	mockService.On("DeleteMessageById", mock.Anything, id).Once().Return(nil)
+	mockService.On("DeleteMessageById", mock.Anything, id, int64(0)).Once().Return(nil)
 
 	req, err := http.NewRequest("DELETE", properties.RootPath+"/message/{id}", nil)
 	if err != nil {
@@ -182,14 +266,59 @@ func TestSaveMessage_InvalidBody(t *testing.T) {
 
 	// then:
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "error.go-example.invalid-request-body", problemCode(t, w))
+}
+
+func TestSaveMessage_ValidationError(t *testing.T) {
+	cases := []struct {
+		body     string
+		httpCode int
+		code     string
+		details  []ctmerror.FieldError
+	}{
+		{`{"text": ""}`, http.StatusUnprocessableEntity, "error.go-example.validation-failed",
+			[]ctmerror.FieldError{{Field: "text", Code: "notblank", Message: "must not be blank"}}},
+		{`{"text": "` + strings.Repeat("a", 257) + `"}`, http.StatusUnprocessableEntity, "error.go-example.validation-failed",
+			[]ctmerror.FieldError{{Field: "text", Code: "max", Message: "must be at most 256 characters long"}}},
+		{`{"text": "MOCK_TEXT", "status": "DELETED"}`, http.StatusBadRequest, "error.go-example.invalid-request-body",
+			[]ctmerror.FieldError{{Field: "status", Code: "unknown", Message: "is not a known field"}}},
+		{`{"text": "MOCK_TEXT", "author": "MOCK_AUTHOR"}`, http.StatusBadRequest, "error.go-example.invalid-request-body",
+			[]ctmerror.FieldError{{Field: "author", Code: "unknown", Message: "is not a known field"}}},
+		{`{"text": 42}`, http.StatusBadRequest, "error.go-example.invalid-request-body",
+			[]ctmerror.FieldError{{Field: "text", Code: "type", Message: "must be of type string"}}},
+	}
+
+	for _, c := range cases {
+		// given:
+		req, err := http.NewRequest("POST", properties.RootPath+"/message", strings.NewReader(c.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// when:
+		handler := http.HandlerFunc(handler.saveMessage)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		// then:
+		problem := ctmerror.Problem{}
+		err = json.Unmarshal(w.Body.Bytes(), &problem)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, c.httpCode, w.Code)
+		assert.Equal(t, c.code, problem.Code)
+		assert.Equal(t, c.details, problem.Details)
+	}
 }
 
 func TestSaveMessage_ServiceError(t *testing.T) {
 	// given:
 	message := model.Message{Text: "MOCK_TEXT"}
-	mockService.On("SaveMessage", mock.Anything, message).Once().Return(nil, unexpectedErr)
+	mockService.On("SaveMessage", mock.Anything, message, "").Once().Return(nil, unexpectedErr)
 
-	requestJson, _ := json.Marshal(message)
+	requestJson, _ := json.Marshal(model.CreateMessageRequest{Text: message.Text})
 	req, err := http.NewRequest("POST", properties.RootPath+"/message", bytes.NewBuffer(requestJson))
 	if err != nil {
 		t.Fatal(err)
@@ -201,11 +330,47 @@ func TestSaveMessage_ServiceError(t *testing.T) {
 	handler.ServeHTTP(w, req)
 
 	// then:
-	assert.Equal(t, "error.go-example.unexpected-error", strings.TrimSpace(w.Body.String()))
+	assert.Equal(t, "error.go-example.unexpected-error", problemCode(t, w))
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	mockService.AssertExpectations(t)
 }
 
+func TestSaveMessage_ProblemResponse(t *testing.T) {
+	// given:
+	message := model.Message{Text: "MOCK_TEXT"}
+	mockService.On("SaveMessage", mock.Anything, message, "").Once().Return(nil, notFoundErr)
+
+	requestJson, _ := json.Marshal(model.CreateMessageRequest{Text: message.Text})
+	req, err := http.NewRequest("POST", properties.RootPath+"/message", bytes.NewBuffer(requestJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := log.WithField(model.LoggerKeyRequestID, "MOCK_REQUEST_ID")
+	req = req.WithContext(context.WithValue(req.Context(), model.ContextLogger, logger))
+
+	// when:
+	handler := http.HandlerFunc(handler.saveMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	problem := ctmerror.Problem{}
+	err = json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "error.go-example.message-not-found", problem.Code)
+	assert.Equal(t, "Message with the given id does not exist", problem.Message)
+	assert.Equal(t, "Not Found", problem.Title)
+	assert.Equal(t, "MOCK_REQUEST_ID", problem.RequestId)
+	assert.Equal(t, properties.RootPath+"/message", problem.Instance)
+	assert.False(t, problem.Timestamp.IsZero())
+	mockService.AssertExpectations(t)
+}
+
 func TestGetMessage_NoParams(t *testing.T) {
 	// given:
 	req, err := http.NewRequest("GET", properties.RootPath+"/message/{id}", nil)
@@ -220,12 +385,13 @@ func TestGetMessage_NoParams(t *testing.T) {
 
 	// then:
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "error.go-example.invalid-id", problemCode(t, w))
 }
 
 func TestEditMessage_NoParams(t *testing.T) {
 	// given:
 	message := model.Message{Text: "UPDATED_TEXT"}
-	requestJson, _ := json.Marshal(message)
+	requestJson, _ := json.Marshal(model.UpdateMessageRequest{Text: message.Text})
 	req, err := http.NewRequest("PUT", properties.RootPath+"/message/{id}", bytes.NewBuffer(requestJson))
 	if err != nil {
 		t.Fatal(err)
@@ -265,9 +431,9 @@ func TestEditMessage_ServiceError(t *testing.T) {
 	for _, errCase := range errorTable {
 		// given:
 		message := model.Message{Text: "UPDATED_TEXT"}
-		mockService.On("UpdateMessageById", mock.Anything, id, message).Once().Return(nil, errCase.msgError)
+		mockService.On("UpdateMessageById", mock.Anything, id, message, int64(0)).Once().Return(nil, errCase.msgError)
 
-		requestJson, _ := json.Marshal(message)
+		requestJson, _ := json.Marshal(model.UpdateMessageRequest{Text: message.Text})
 		req, err := http.NewRequest("PUT", properties.RootPath+"/message/{id}", bytes.NewBuffer(requestJson))
 		if err != nil {
 			t.Fatal(err)
@@ -284,7 +450,7 @@ func TestEditMessage_ServiceError(t *testing.T) {
 
 		// then:
 		assert.Equal(t, errCase.httpCode, w.Code)
-		assert.Equal(t, errCase.errorCode, strings.TrimSpace(w.Body.String()))
+		assert.Equal(t, errCase.errorCode, problemCode(t, w))
 		mockService.AssertExpectations(t)
 	}
 }
@@ -308,7 +474,7 @@ func TestDeleteMessage_NoParams(t *testing.T) {
 func TestDeleteMessage_ServiceError(t *testing.T) {
 	for _, errCase := range errorTable {
 		// given:
-		mockService.On("DeleteMessageById", mock.Anything, id).Once().Return(errCase.msgError)
+		mockService.On("DeleteMessageById", mock.Anything, id, int64(0)).Once().Return(errCase.msgError)
 
 		req, err := http.NewRequest("DELETE", properties.RootPath+"/message/{id}", nil)
 		if err != nil {
@@ -326,7 +492,503 @@ func TestDeleteMessage_ServiceError(t *testing.T) {
 
 		// then:
 		assert.Equal(t, errCase.httpCode, w.Code)
-		assert.Equal(t, errCase.errorCode, strings.TrimSpace(w.Body.String()))
+		assert.Equal(t, errCase.errorCode, problemCode(t, w))
+		mockService.AssertExpectations(t)
+	}
+}
+
+func TestListMessages_Ok(t *testing.T) {
+	// given:
+	page := model.MessagePage{
+		Items: []model.Message{{Id: id, Text: "MOCK_TEXT", Status: "CREATED"}},
+		Next:  "MOCK_CURSOR",
+	}
+	mockService.On("ListMessages", mock.Anything, model.MessageFilter{}, "PREV_CURSOR", 10).Once().Return(&page, nil)
+
+	req, err := http.NewRequest("GET", properties.RootPath+"/message?limit=10&cursor=PREV_CURSOR", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.listMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	result := model.MessagePageResponse{}
+	err = json.Unmarshal(w.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, model.NewMessagePageResponse(&page), result)
+	mockService.AssertExpectations(t)
+}
+
+func TestListMessages_InvalidLimit(t *testing.T) {
+	// given:
+	req, err := http.NewRequest("GET", properties.RootPath+"/message?limit=abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.listMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestListMessages_WithFilter(t *testing.T) {
+	// given:
+	createdFrom := time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC)
+	filter := model.MessageFilter{
+		Status:      model.CREATED,
+		CreatedFrom: &createdFrom,
+		Query:       "hello world",
+	}
+	page := model.MessagePage{Items: []model.Message{{Id: id, Text: "hello world", Status: "CREATED"}}}
+	mockService.On("ListMessages", mock.Anything, filter, "", 0).Once().Return(&page, nil)
+
+	req, err := http.NewRequest("GET", properties.RootPath+"/message?status=CREATED&createdFrom=2020-04-01T00:00:00Z&q=hello+world", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.listMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestListMessages_InvalidFilter(t *testing.T) {
+	for _, query := range []string{"status=UNKNOWN", "createdFrom=yesterday", "updatedTo=2020-13-01"} {
+		// given:
+		req, err := http.NewRequest("GET", properties.RootPath+"/message?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// when:
+		handler := http.HandlerFunc(handler.listMessages)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		// then:
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "error.go-example.invalid-filter", problemCode(t, w))
+	}
+}
+
+func TestListMessages_ServiceError(t *testing.T) {
+	// given:
+	invalidCursorErr := ctmerror.NewMessageErrorBuilder("error.go-example.invalid-cursor", assert.AnError, 400)
+	mockService.On("ListMessages", mock.Anything, model.MessageFilter{}, "INVALID_CURSOR", 0).Once().Return(nil, invalidCursorErr)
+
+	req, err := http.NewRequest("GET", properties.RootPath+"/message?cursor=INVALID_CURSOR", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	handler := http.HandlerFunc(handler.listMessages)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "error.go-example.invalid-cursor", problemCode(t, w))
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreMessage_Ok(t *testing.T) {
+	// given:
+	restoredMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	mockService.On("RestoreMessageById", mock.Anything, id).Once().Return(&restoredMessage, nil)
+
+	req, err := http.NewRequest("POST", properties.RootPath+"/message/{id}/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.restoreMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	result := model.MessageResponse{}
+	err = json.Unmarshal(w.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, model.NewMessageResponse(&restoredMessage), result)
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreMessage_ServiceError(t *testing.T) {
+	for _, errCase := range errorTable {
+		// given:
+		mockService.On("RestoreMessageById", mock.Anything, id).Once().Return(nil, errCase.msgError)
+
+		req, err := http.NewRequest("POST", properties.RootPath+"/message/{id}/restore", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req = mux.SetURLVars(req, map[string]string{
+			"id": "1",
+		})
+
+		// when:
+		handler := http.HandlerFunc(handler.restoreMessage)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		// then:
+		assert.Equal(t, errCase.httpCode, w.Code)
+		assert.Equal(t, errCase.errorCode, problemCode(t, w))
 		mockService.AssertExpectations(t)
 	}
 }
+
+func TestGetMessage_ETag(t *testing.T) {
+	// given:
+	message := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 2,
+	}
+	mockService.On("GetMessageById", mock.Anything, id, false).Twice().Return(&message, nil)
+
+	for _, c := range []struct {
+		ifNoneMatch string
+		httpCode    int
+	}{
+		{`"1"`, http.StatusOK},
+		{`"1", W/"2"`, http.StatusNotModified},
+	} {
+		req, err := http.NewRequest("GET", properties.RootPath+"/message/{id}", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", c.ifNoneMatch)
+
+		req = mux.SetURLVars(req, map[string]string{
+			"id": "1",
+		})
+
+		// when:
+		handler := http.HandlerFunc(handler.getMessage)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		// then:
+		assert.Equal(t, c.httpCode, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestEditMessage_IfMatch(t *testing.T) {
+	// given:
+	message := model.Message{Text: "UPDATED_TEXT"}
+	updatedMessage := model.Message{
+		Id:      id,
+		Text:    "UPDATED_TEXT",
+		Status:  "CREATED",
+		Version: 4,
+	}
+	mockService.On("UpdateMessageById", mock.Anything, id, message, int64(3)).Once().Return(&updatedMessage, nil)
+
+	requestJson, _ := json.Marshal(model.UpdateMessageRequest{Text: message.Text})
+	req, err := http.NewRequest("PUT", properties.RootPath+"/message/{id}", bytes.NewBuffer(requestJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `"3"`)
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.editMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	mockService.AssertExpectations(t)
+}
+
+func TestEditMessage_InvalidIfMatch(t *testing.T) {
+	// given:
+	requestJson, _ := json.Marshal(model.UpdateMessageRequest{Text: "UPDATED_TEXT"})
+	req, err := http.NewRequest("PUT", properties.RootPath+"/message/{id}", bytes.NewBuffer(requestJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", "3")
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.editMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteMessage_PreconditionFailed(t *testing.T) {
+	// given:
+	preconditionErr := ctmerror.NewMessageErrorBuilder("error.go-example.precondition-failed", assert.AnError, 412)
+	mockService.On("DeleteMessageById", mock.Anything, id, int64(5)).Once().Return(preconditionErr)
+
+	req, err := http.NewRequest("DELETE", properties.RootPath+"/message/{id}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `"5"`)
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.deleteMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, "error.go-example.precondition-failed", problemCode(t, w))
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteMessage_IfMatchList(t *testing.T) {
+	// given:
+	current := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 3}
+	mockService.On("GetMessageById", mock.Anything, id, false).Once().Return(&current, nil)
+	mockService.On("DeleteMessageById", mock.Anything, id, int64(3)).Once().Return(nil)
+
+	req, err := http.NewRequest("DELETE", properties.RootPath+"/message/{id}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `"2", W/"4", "3"`)
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.deleteMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteMessage_WeakIfMatch(t *testing.T) {
+	// given:
+	preconditionErr := ctmerror.NewMessageErrorBuilder("error.go-example.precondition-failed", assert.AnError, 412)
+	mockService.On("DeleteMessageById", mock.Anything, id, noVersion).Once().Return(preconditionErr)
+
+	req, err := http.NewRequest("DELETE", properties.RootPath+"/message/{id}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `W/"5"`)
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.deleteMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchMessage_Ok(t *testing.T) {
+	// given:
+	document := []byte(`{"text":"PATCHED_TEXT"}`)
+	patch := model.MessagePatch{Type: model.MergePatch, Document: document}
+	patchedMessage := model.Message{
+		Id:      id,
+		Text:    "PATCHED_TEXT",
+		Status:  "CREATED",
+		Version: 4,
+	}
+	mockService.On("PatchMessageById", mock.Anything, id, patch, int64(3)).Once().Return(&patchedMessage, nil)
+
+	req, err := http.NewRequest("PATCH", properties.RootPath+"/message/{id}", bytes.NewBuffer(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/merge-patch+json; charset=utf-8")
+	req.Header.Set("If-Match", `"3"`)
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.patchMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	response := model.MessageResponse{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	assert.Equal(t, model.NewMessageResponse(&patchedMessage), response)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchMessage_MissingContentType(t *testing.T) {
+	// given:
+	req, err := http.NewRequest("PATCH", properties.RootPath+"/message/{id}", strings.NewReader(`{"text":"PATCHED_TEXT"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.patchMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, "error.go-example.unsupported-media-type", problemCode(t, w))
+}
+
+func TestListRevisions_Ok(t *testing.T) {
+	// given:
+	revisions := []model.MessageRevision{
+		{MessageId: id, Revision: 1, Text: "MOCK_TEXT", Status: "CREATED", Actor: model.ActorAnonymous},
+		{MessageId: id, Revision: 2, Text: "UPDATED_TEXT", Status: "CREATED", Actor: model.ActorAdmin},
+	}
+	mockService.On("ListMessageRevisions", mock.Anything, id).Once().Return(revisions, nil)
+
+	req, err := http.NewRequest("GET", properties.RootPath+"/message/{id}/revisions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id": "1",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.listRevisions)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	response := model.MessageRevisionListResponse{}
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, model.NewMessageRevisionListResponse(revisions), response)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetRevision_InvalidRevision(t *testing.T) {
+	// given:
+	req, err := http.NewRequest("GET", properties.RootPath+"/message/{id}/revisions/{revision}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id":       "1",
+		"revision": "0",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.getRevision)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "error.go-example.invalid-revision", problemCode(t, w))
+}
+
+func TestRevertMessage_Ok(t *testing.T) {
+	// given:
+	revertedMessage := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 5,
+	}
+	mockService.On("RevertMessageById", mock.Anything, id, int64(2), int64(4)).Once().Return(&revertedMessage, nil)
+
+	req, err := http.NewRequest("POST", properties.RootPath+"/message/{id}/revisions/{revision}/revert", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("If-Match", `"4"`)
+
+	req = mux.SetURLVars(req, map[string]string{
+		"id":       "1",
+		"revision": "2",
+	})
+
+	// when:
+	handler := http.HandlerFunc(handler.revertMessage)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	// then:
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	mockService.AssertExpectations(t)
+}
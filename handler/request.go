@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/validation"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+// maxBodySize limits size of request bodies, message text itself is at most 256 characters
+const maxBodySize = 64 << 10
+
+// maxBatchBodySize limits size of batch request bodies, see service.MaxBatchSize
+const maxBatchBodySize = 1 << 20
+
+// maxIdempotencyKeyLength is the size of idempotency_key.key column
+const maxIdempotencyKeyLength = 255
+
+// decodeBody strictly decodes JSON request body into v and validates it
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) *ctmerror.MessageError {
+	return validation.Decode(http.MaxBytesReader(w, r.Body, maxBodySize), v)
+}
+
+// readPatch reads patch document from request body, its kind is taken from Content-Type header
+func readPatch(w http.ResponseWriter, r *http.Request) (model.MessagePatch, *ctmerror.MessageError) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return model.MessagePatch{}, ctmerror.NewMessageErrorBuilder("error.go-example.unsupported-media-type", err, http.StatusUnsupportedMediaType)
+	}
+
+	document, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		return model.MessagePatch{}, ctmerror.NewMessageErrorBuilder(validation.InvalidBodyErrorCode, err, http.StatusBadRequest)
+	}
+	return model.MessagePatch{Type: model.PatchType(mediaType), Document: document}, nil
+}
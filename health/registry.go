@@ -0,0 +1,134 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Component statuses of the readiness report
+const (
+	StatusUp   = "UP"
+	StatusDown = "DOWN"
+)
+
+// Checker verifies that a single dependency of the application is ready to serve traffic
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to use ordinary functions as Checker
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// ComponentReport is the result of a single check
+type ComponentReport struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the result of all registered checks, Status is UP only when every component is UP
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentReport `json:"components"`
+}
+
+// Registry holds named checkers and runs them concurrently, each bounded by Timeout
+type Registry struct {
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	checkers map[string]Checker
+}
+
+// NewRegistry returns empty registry with given per-check timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		Timeout:  timeout,
+		checkers: map[string]Checker{},
+	}
+}
+
+// Register adds checker under the given name, replacing previously registered one
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers[name] = checker
+}
+
+// Names returns sorted names of registered checkers
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.checkers))
+	for name := range r.checkers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check runs all registered checks and collects their results
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := make(map[string]Checker, len(r.checkers))
+	for name, checker := range r.checkers {
+		checkers[name] = checker
+	}
+	r.mu.RUnlock()
+
+	report := Report{
+		Status:     StatusUp,
+		Components: make(map[string]ComponentReport, len(checkers)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+			component := r.runCheck(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = component
+			if component.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, checker Checker) ComponentReport {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	component := ComponentReport{
+		Status:   StatusUp,
+		Duration: time.Since(start).String(),
+	}
+	if err != nil {
+		component.Status = StatusDown
+		component.Error = err.Error()
+	}
+	return component
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Check_Up(t *testing.T) {
+	// given:
+	registry := NewRegistry(time.Second)
+	registry.Register("db", CheckerFunc(func(ctx context.Context) error { return nil }))
+	registry.Register("migrations", CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	// when:
+	report := registry.Check(context.Background())
+
+	// then:
+	assert.Equal(t, StatusUp, report.Status)
+	assert.Equal(t, []string{"db", "migrations"}, registry.Names())
+	assert.Equal(t, StatusUp, report.Components["db"].Status)
+	assert.Equal(t, StatusUp, report.Components["migrations"].Status)
+}
+
+func TestRegistry_Check_Down(t *testing.T) {
+	// given:
+	registry := NewRegistry(time.Second)
+	registry.Register("db", CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+	registry.Register("migrations", CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	// when:
+	report := registry.Check(context.Background())
+
+	// then:
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusDown, report.Components["db"].Status)
+	assert.Equal(t, "connection refused", report.Components["db"].Error)
+	assert.Equal(t, StatusUp, report.Components["migrations"].Status)
+}
+
+func TestRegistry_Check_Timeout(t *testing.T) {
+	// given:
+	registry := NewRegistry(10 * time.Millisecond)
+	registry.Register("db", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	// when:
+	report := registry.Check(context.Background())
+
+	// then:
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["db"].Error)
+}
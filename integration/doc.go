@@ -0,0 +1,7 @@
+// Package integration drives the whole application, from HTTP down to PostgreSQL, through
+// httptest.Server. Tests are built only with the integration tag and need a running PostgreSQL
+// the given user may create databases in, every run works in a fresh temporary database:
+//
+//	INTEGRATION_DB_URL=localhost:5432 INTEGRATION_DB_USER=postgres INTEGRATION_DB_PASS=postgres \
+//		go test -tags integration ./integration/...
+package integration
@@ -0,0 +1,113 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"fmt"
+	"github.com/FatimaBabayeva/ms-go-example/app"
+	"github.com/FatimaBabayeva/ms-go-example/properties"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/go-pg/pg"
+	"github.com/golang-jwt/jwt/v4"
+	log "github.com/sirupsen/logrus"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	adminKey   = "integration_admin_key"
+	authSecret = "integration_auth_secret"
+	subject    = "integration-user"
+)
+
+var (
+	config properties.Config
+	server *httptest.Server
+	// token is sent by every request unless it overrides Authorization header
+	token string
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	addr := os.Getenv("INTEGRATION_DB_URL")
+	if addr == "" {
+		fmt.Println("INTEGRATION_DB_URL is not set, see package documentation")
+		return 1
+	}
+	// migrations are looked up relative to the project root
+	if err := os.Chdir(".."); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// the maintenance database is only used to create and drop the database tests run in
+	addr = strings.Split(addr, "/")[0]
+	admin := pg.Connect(&pg.Options{
+		Addr:     addr,
+		Database: "postgres",
+		User:     os.Getenv("INTEGRATION_DB_USER"),
+		Password: os.Getenv("INTEGRATION_DB_PASS"),
+	})
+	defer admin.Close()
+
+	database := fmt.Sprintf("ms_go_example_it_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE DATABASE " + database); err != nil {
+		fmt.Println("Error creating database: ", err)
+		return 1
+	}
+	defer func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + database); err != nil {
+			fmt.Println("Error dropping database: ", err)
+		}
+	}()
+
+	config = properties.Config{
+		DbUrl:              addr + "/" + database,
+		DbUser:             os.Getenv("INTEGRATION_DB_USER"),
+		DbPass:             os.Getenv("INTEGRATION_DB_PASS"),
+		DbTimeout:          5 * time.Second,
+		IdempotencyTTL:     time.Hour,
+		AdminKey:           adminKey,
+		AuthEnabled:        true,
+		AuthHmacSecret:     authSecret,
+		HealthCheckTimeout: 2 * time.Second,
+	}
+	if err := repo.MigrateDb(config); err != nil {
+		fmt.Println("Error migrating database: ", err)
+		return 1
+	}
+
+	var err error
+	token, err = signToken(subject, time.Now().Add(time.Hour))
+	if err != nil {
+		fmt.Println("Error signing token: ", err)
+		return 1
+	}
+
+	log.SetLevel(log.WarnLevel)
+	application, err := app.New(config)
+	if err != nil {
+		fmt.Println("Error building application: ", err)
+		return 1
+	}
+	server = httptest.NewServer(application.Router)
+	defer application.Shutdown()
+	defer server.Close()
+
+	return m.Run()
+}
+
+// signToken issues HS256 token accepted by the test server
+func signToken(sub string, expiresAt time.Time) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   sub,
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}).SignedString([]byte(authSecret))
+}
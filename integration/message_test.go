@@ -0,0 +1,515 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/health"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/properties"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/go-pg/pg"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// call sends request to the test server and returns response with its body read
+func call(t *testing.T, method string, path string, body string, headers map[string]string) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, resBody
+}
+
+func decode(t *testing.T, body []byte, v interface{}) {
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("%v: %s", err, body)
+	}
+}
+
+func createMessage(t *testing.T, text string) model.MessageResponse {
+	res, body := call(t, "POST", properties.RootPath+"/message", fmt.Sprintf(`{"text":%q}`, text), nil)
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", res.StatusCode, body)
+	}
+
+	var message model.MessageResponse
+	decode(t, body, &message)
+	return message
+}
+
+func messagePath(id int64) string {
+	return fmt.Sprintf("%s/message/%d", properties.RootPath, id)
+}
+
+func problemCode(t *testing.T, body []byte) string {
+	var problem ctmerror.Problem
+	decode(t, body, &problem)
+	return problem.Code
+}
+
+func TestReadiness(t *testing.T) {
+	// when:
+	res, body := call(t, "GET", "/readiness", "", nil)
+
+	// then:
+	var report health.Report
+	decode(t, body, &report)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, health.StatusUp, report.Components["db"].Status)
+	assert.Equal(t, health.StatusUp, report.Components["migrations"].Status)
+}
+
+func TestAuthentication(t *testing.T) {
+	expired, err := signToken(subject, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		token  string
+		status int
+		code   string
+	}{
+		{"missing token", properties.RootPath + "/message", "", http.StatusUnauthorized, "error.go-example.unauthorized"},
+		{"expired token", properties.RootPath + "/message", "Bearer " + expired, http.StatusUnauthorized, "error.go-example.token-expired"},
+		{"forged token", properties.RootPath + "/message", "Bearer " + token + "x", http.StatusUnauthorized, "error.go-example.unauthorized"},
+		{"health check", "/health", "", http.StatusOK, ""},
+		{"metrics", "/metrics", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when:
+			res, body := call(t, "GET", tt.path, "", map[string]string{"Authorization": tt.token})
+
+			// then:
+			assert.Equal(t, tt.status, res.StatusCode)
+			if tt.code != "" {
+				assert.Equal(t, tt.code, problemCode(t, body))
+				assert.Contains(t, res.Header.Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestOwnership(t *testing.T) {
+	// given:
+	created := createMessage(t, "owned text")
+	assert.Equal(t, subject, created.OwnerId)
+	other, err := signToken("other-user", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherHeaders := map[string]string{"Authorization": "Bearer " + other}
+
+	// when:
+	getRes, getBody := call(t, "GET", messagePath(created.Id), "", otherHeaders)
+	putRes, _ := call(t, "PUT", messagePath(created.Id), `{"text":"stolen text"}`, otherHeaders)
+	deleteRes, _ := call(t, "DELETE", messagePath(created.Id), "", otherHeaders)
+	listRes, listBody := call(t, "GET", properties.RootPath+"/message?limit=100", "", otherHeaders)
+	adminRes, _ := call(t, "GET", messagePath(created.Id), "",
+		map[string]string{"Authorization": "Bearer " + other, model.HeaderKeyAdminKey: adminKey})
+
+	// then:
+	assert.Equal(t, http.StatusForbidden, getRes.StatusCode)
+	assert.Equal(t, "error.go-example.access-denied", problemCode(t, getBody))
+	assert.Equal(t, http.StatusForbidden, putRes.StatusCode)
+	assert.Equal(t, http.StatusForbidden, deleteRes.StatusCode)
+	assert.Equal(t, http.StatusOK, adminRes.StatusCode)
+
+	var page model.MessagePageResponse
+	decode(t, listBody, &page)
+	assert.Equal(t, http.StatusOK, listRes.StatusCode)
+	assert.Empty(t, page.Items)
+}
+
+func TestMessageLifecycle(t *testing.T) {
+	// create
+	created := createMessage(t, "first text")
+	assert.NotZero(t, created.Id)
+	assert.Equal(t, model.CREATED, created.Status)
+
+	// get
+	res, body := call(t, "GET", messagePath(created.Id), "", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+
+	res, _ = call(t, "GET", messagePath(created.Id), "", map[string]string{"If-None-Match": `"1"`})
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+
+	// update
+	res, body = call(t, "PUT", messagePath(created.Id), `{"text":"second text"}`, map[string]string{"If-Match": `"1"`})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+	// patch
+	res, body = call(t, "PATCH", messagePath(created.Id), `{"text":"third text"}`,
+		map[string]string{"Content-Type": string(model.MergePatch), "If-Match": `"2"`})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var patched model.MessageResponse
+	decode(t, body, &patched)
+	assert.Equal(t, "third text", patched.Text)
+
+	// history
+	res, body = call(t, "GET", messagePath(created.Id)+"/revisions", "", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var revisions model.MessageRevisionListResponse
+	decode(t, body, &revisions)
+	if assert.Len(t, revisions.Items, 3) {
+		assert.Equal(t, "first text", revisions.Items[0].Text)
+		assert.Equal(t, "third text", revisions.Items[2].Text)
+		assert.Equal(t, subject, revisions.Items[2].Actor)
+	}
+
+	// revert
+	res, body = call(t, "POST", messagePath(created.Id)+"/revisions/1/revert", "", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, `"4"`, res.Header.Get("ETag"))
+
+	var reverted model.MessageResponse
+	decode(t, body, &reverted)
+	assert.Equal(t, "first text", reverted.Text)
+
+	// delete
+	res, _ = call(t, "DELETE", messagePath(created.Id), "", map[string]string{"If-Match": `"4"`})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, body = call(t, "GET", messagePath(created.Id), "", nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, "error.go-example.message-not-found", problemCode(t, body))
+
+	// restore
+	res, _ = call(t, "POST", messagePath(created.Id)+"/restore", "", map[string]string{model.HeaderKeyAdminKey: adminKey})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, _ = call(t, "GET", messagePath(created.Id), "", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestListAndSearch(t *testing.T) {
+	// given:
+	marker := fmt.Sprintf("marker%d", time.Now().UnixNano())
+	for i := 0; i < 5; i++ {
+		createMessage(t, fmt.Sprintf("%s message number %d", marker, i))
+	}
+
+	// when:
+	res, body := call(t, "GET", properties.RootPath+"/message?limit=3&q="+marker, "", nil)
+
+	// then:
+	var firstPage model.MessagePageResponse
+	decode(t, body, &firstPage)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Len(t, firstPage.Items, 3)
+	assert.NotEmpty(t, firstPage.Next)
+
+	res, body = call(t, "GET", properties.RootPath+"/message?limit=3&q="+marker+"&cursor="+firstPage.Next, "", nil)
+
+	var secondPage model.MessagePageResponse
+	decode(t, body, &secondPage)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Len(t, secondPage.Items, 2)
+	assert.Empty(t, secondPage.Next)
+}
+
+func TestKeysetPagination(t *testing.T) {
+	// given:
+	from := time.Now().UTC().Format(time.RFC3339Nano)
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < 4; i++ {
+		createMessage(t, fmt.Sprintf("page message %d", i))
+	}
+	path := properties.RootPath + "/message?limit=3&createdFrom=" + from
+
+	// when:
+	_, body := call(t, "GET", path, "", nil)
+	var firstPage model.MessagePageResponse
+	decode(t, body, &firstPage)
+
+	_, body = call(t, "GET", path+"&cursor="+firstPage.Next, "", nil)
+	var secondPage model.MessagePageResponse
+	decode(t, body, &secondPage)
+
+	// then:
+	assert.Len(t, firstPage.Items, 3)
+	assert.Len(t, secondPage.Items, 1)
+	assert.Equal(t, "page message 0", secondPage.Items[0].Text)
+}
+
+func TestErrorPaths(t *testing.T) {
+	cases := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		headers   map[string]string
+		status    int
+		errorCode string
+	}{
+		{"missing message", "GET", messagePath(999999999), "", nil, http.StatusNotFound, "error.go-example.message-not-found"},
+		{"malformed body", "POST", properties.RootPath + "/message", `{"text":`, nil, http.StatusBadRequest, "error.go-example.invalid-request-body"},
+		{"too long text", "POST", properties.RootPath + "/message", fmt.Sprintf(`{"text":%q}`, strings.Repeat("a", 257)), nil, http.StatusUnprocessableEntity, "error.go-example.validation-failed"},
+		{"invalid id", "GET", properties.RootPath + "/message/abc", "", nil, http.StatusBadRequest, "error.go-example.invalid-id"},
+		{"deleted for non-admin", "GET", properties.RootPath + "/message?includeDeleted=true", "", nil, http.StatusForbidden, "error.go-example.forbidden"},
+		{"missing revision", "GET", messagePath(createMessage(t, "revisions").Id) + "/revisions/9", "", nil, http.StatusNotFound, "error.go-example.revision-not-found"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// when:
+			res, body := call(t, c.method, c.path, c.body, c.headers)
+
+			// then:
+			assert.Equal(t, c.status, res.StatusCode)
+			assert.Equal(t, ctmerror.ProblemContentType, res.Header.Get("Content-Type"))
+			assert.Equal(t, c.errorCode, problemCode(t, body))
+		})
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	// given:
+	created := createMessage(t, "contended text")
+	const writers = 10
+
+	// when:
+	statuses := make(chan int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, _ := call(t, "PUT", messagePath(created.Id), fmt.Sprintf(`{"text":"writer %d"}`, i),
+				map[string]string{"If-Match": `"1"`})
+			statuses <- res.StatusCode
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	// then:
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	assert.Equal(t, 1, counts[http.StatusOK])
+	assert.Equal(t, writers-1, counts[http.StatusPreconditionFailed])
+
+	res, _ := call(t, "GET", messagePath(created.Id), "", nil)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	// given:
+	const writers = 20
+
+	// when:
+	ids := make(chan int64, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids <- createMessage(t, fmt.Sprintf("concurrent %d", i)).Id
+		}(i)
+	}
+	wg.Wait()
+	close(ids)
+
+	// then:
+	seen := make(map[int64]bool)
+	for id := range ids {
+		assert.False(t, seen[id], "duplicate id %d", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, writers)
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	// given:
+	key := map[string]string{model.HeaderKeyIdempotencyKey: fmt.Sprintf("key-%d", time.Now().UnixNano())}
+	const retries = 5
+
+	// when:
+	bodies := make(chan []byte, retries)
+	var wg sync.WaitGroup
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, body := call(t, "POST", properties.RootPath+"/message", `{"text":"idempotent text"}`, key)
+			assert.Equal(t, http.StatusCreated, res.StatusCode)
+			bodies <- body
+		}()
+	}
+	wg.Wait()
+	close(bodies)
+
+	res, body := call(t, "POST", properties.RootPath+"/message", `{"text":"different text"}`, key)
+
+	// the key of another user is a different key
+	other, err := signToken("other-user", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRes, _ := call(t, "POST", properties.RootPath+"/message", `{"text":"different text"}`, map[string]string{
+		model.HeaderKeyIdempotencyKey: key[model.HeaderKeyIdempotencyKey],
+		"Authorization":               "Bearer " + other,
+	})
+
+	// then:
+	ids := make(map[int64]bool)
+	for body := range bodies {
+		var message model.MessageResponse
+		decode(t, body, &message)
+		ids[message.Id] = true
+	}
+	assert.Len(t, ids, 1)
+
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+	assert.Equal(t, "error.go-example.idempotency-key-reused", problemCode(t, body))
+	assert.Equal(t, http.StatusCreated, otherRes.StatusCode)
+}
+
+// batchStatuses returns statuses of batch items, in the order of the request
+func batchStatuses(t *testing.T, res *http.Response, body []byte) []int {
+	assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+
+	var batch struct {
+		Items []struct {
+			Status  int                    `json:"status"`
+			Message *model.MessageResponse `json:"message"`
+		} `json:"items"`
+	}
+	decode(t, body, &batch)
+
+	statuses := make([]int, 0, len(batch.Items))
+	for _, item := range batch.Items {
+		statuses = append(statuses, item.Status)
+	}
+	return statuses
+}
+
+func TestBatch(t *testing.T) {
+	// create
+	res, body := call(t, "POST", properties.RootPath+"/message/batch", `[{"text":"batch one"},{"text":"batch two"}]`, nil)
+	assert.Equal(t, []int{http.StatusCreated, http.StatusCreated}, batchStatuses(t, res, body))
+
+	first, second := createMessage(t, "batch three"), createMessage(t, "batch four")
+
+	// atomic update with a stale version changes nothing
+	res, body = call(t, "PUT", properties.RootPath+"/message/batch",
+		fmt.Sprintf(`[{"id":%d,"text":"changed","version":%d},{"id":%d,"text":"changed","version":7}]`,
+			first.Id, first.Version, second.Id), nil)
+	assert.Equal(t, []int{http.StatusFailedDependency, http.StatusPreconditionFailed}, batchStatuses(t, res, body))
+
+	res, _ = call(t, "GET", messagePath(first.Id), "", nil)
+	assert.Equal(t, `"1"`, res.Header.Get("ETag"))
+
+	// per-item update applies the valid items
+	res, body = call(t, "PUT", properties.RootPath+"/message/batch?mode=per-item",
+		fmt.Sprintf(`[{"id":%d,"text":"changed","version":%d},{"id":%d,"text":"changed","version":7}]`,
+			first.Id, first.Version, second.Id), nil)
+	assert.Equal(t, []int{http.StatusOK, http.StatusPreconditionFailed}, batchStatuses(t, res, body))
+
+	res, _ = call(t, "GET", messagePath(first.Id), "", nil)
+	assert.Equal(t, `"2"`, res.Header.Get("ETag"))
+
+	// delete
+	res, body = call(t, "DELETE", properties.RootPath+"/message/batch",
+		fmt.Sprintf(`[{"id":%d,"version":2},{"id":%d,"version":%d}]`, first.Id, second.Id, second.Version), nil)
+	assert.Equal(t, []int{http.StatusNoContent, http.StatusNoContent}, batchStatuses(t, res, body))
+
+	res, _ = call(t, "GET", messagePath(second.Id), "", nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	db := repo.NewDb(config)
+	defer db.Close()
+	revisions, err := repo.NewMessageRepo(db, config.DbTimeout).ListRevisions(context.Background(), first.Id)
+	assert.Nil(t, err)
+	assert.Len(t, revisions, 3)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+	// given:
+	db := repo.NewDb(config)
+	defer db.Close()
+	msgRepo := repo.NewMessageRepo(db, config.DbTimeout)
+
+	created := createMessage(t, "purged text")
+	res, _ := call(t, "DELETE", messagePath(created.Id), "", nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	// when:
+	n, err := msgRepo.PurgeDeleted(context.Background(), time.Now().Add(time.Minute), 1000)
+
+	// then:
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, n, 1)
+
+	_, err = msgRepo.Get(context.Background(), created.Id)
+	assert.Equal(t, pg.ErrNoRows, err)
+
+	revisions, err := msgRepo.ListRevisions(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.Empty(t, revisions)
+}
+
+func TestRunInTx_Rollback(t *testing.T) {
+	// given:
+	db := repo.NewDb(config)
+	defer db.Close()
+	msgRepo := repo.NewMessageRepo(db, config.DbTimeout)
+	created := createMessage(t, "transactional text")
+
+	// when:
+	err := msgRepo.RunInTx(context.Background(), func(tx repo.MessageRepo) error {
+		m, err := tx.GetForUpdate(context.Background(), created.Id)
+		if err != nil {
+			return err
+		}
+		m.Text = "changed in rolled back transaction"
+		if _, err := tx.Update(context.Background(), m); err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+
+	// then:
+	assert.Equal(t, assert.AnError, err)
+	m, err := msgRepo.Get(context.Background(), created.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "transactional text", m.Text)
+	assert.Equal(t, int64(1), m.Version)
+}
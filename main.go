@@ -1,47 +1,107 @@
 package main
 
 import (
-	"github.com/FatimaBabayeva/ms-go-example/handler"
+	"context"
+	"fmt"
+	"github.com/FatimaBabayeva/ms-go-example/app"
 	"github.com/FatimaBabayeva/ms-go-example/properties"
 	"github.com/FatimaBabayeva/ms-go-example/repo"
-	"github.com/gorilla/mux"
+	"github.com/FatimaBabayeva/ms-go-example/tracing"
 	"github.com/jessevdk/go-flags"
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
-	"net/http"
-	"strconv"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 var opts struct {
 	Profile string `short:"p" long:"profile" default:"default" description:"Application run profile"`
+	Storage string `long:"storage" choice:"postgres" choice:"memory" description:"Message storage, overrides STORAGE"`
+	// NoMigrate is for deployments running migrations as a separate job, see migrateCommand
+	NoMigrate bool `long:"no-migrate" description:"Do not apply pending migrations at startup, overrides MIGRATE_ON_START"`
+
+	Migrate migrateCommand `command:"migrate" description:"Manage Db migrations instead of starting the application"`
 }
 
 func main() {
-	_, err := flags.Parse(&opts)
-	if err != nil {
-		panic(err)
+	parser := flags.NewParser(&opts, flags.Default)
+	parser.SubcommandsOptional = true
+	if _, err := parser.Parse(); err != nil {
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+	if parser.Active != nil {
+		// a command was executed instead of starting the application
+		return
 	}
 
-	initLogger()
-	initEnvVars()
-	properties.LoadConfig()
-	applyLoggerLevel()
-
+	config := loadConfig()
 	log.Info("Application is starting with profile: ", opts.Profile)
 
-	err = repo.MigrateDb()
+	shutdownTracing, err := tracing.Init(tracing.Config{
+		Exporter:    config.TracingExporter,
+		Endpoint:    config.TracingEndpoint,
+		Insecure:    config.TracingInsecure,
+		SampleRatio: config.TracingSampleRatio,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	application, err := newApp(config)
 	if err != nil {
 		panic(err)
 	}
-	repo.InitDb()
+	application.Start()
 
-	router := mux.NewRouter()
-	handler.NewMessageHandler(router)
-	handler.HandleHealthRequest(router)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	log.Info("Received signal: ", <-stop, ", application is stopping")
 
-	port := strconv.Itoa(properties.Props.Port)
-	log.Info("Starting server at port: ", port)
-	log.Fatal(http.ListenAndServe(":"+port, router))
+	application.Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("Error flushing traces: ", err)
+	}
+	log.Info("Application is stopped")
+}
+
+// newApp builds the application on the configured storage, migrating Db when it is used and enabled
+func newApp(config properties.Config) (*app.App, error) {
+	switch config.Storage {
+	case properties.StoragePostgres, "":
+		if config.MigrateOnStart {
+			if err := repo.MigrateDb(config); err != nil {
+				return nil, err
+			}
+		}
+		return app.New(config)
+	case properties.StorageMemory:
+		log.Warn("Messages are stored in memory and will be lost when the application stops")
+		return app.NewWithRepo(config, repo.NewMemoryMessageRepo())
+	default:
+		return nil, fmt.Errorf("unknown storage %q", config.Storage)
+	}
+}
+
+// loadConfig reads configuration of the selected profile, applying command line overrides
+func loadConfig() properties.Config {
+	initLogger()
+	initEnvVars()
+	config := properties.LoadConfig()
+	if opts.Storage != "" {
+		config.Storage = opts.Storage
+	}
+	if opts.NoMigrate {
+		config.MigrateOnStart = false
+	}
+	applyLoggerLevel(config)
+	return config
 }
 
 func initEnvVars() {
@@ -68,8 +128,8 @@ func initLogger() {
 	}
 }
 
-func applyLoggerLevel() {
-	loglevel, err := log.ParseLevel(properties.Props.LogLevel)
+func applyLoggerLevel(config properties.Config) {
+	loglevel, err := log.ParseLevel(config.LogLevel)
 	if err != nil {
 		loglevel = log.InfoLevel
 	}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/go-pg/pg"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "go_example"
+
+// ResultOk is the result label of operations completed without error
+const ResultOk = "ok"
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of handled HTTP requests.",
+	}, []string{"method", "route", "code"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of handled HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	serviceOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "operations_total",
+		Help:      "Number of message service operations by result, which is either ok or an error code.",
+	}, []string{"operation", "result"})
+
+	serviceDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "service",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of message service operations.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	purgeRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "purger",
+		Name:      "runs_total",
+		Help:      "Number of purge runs of soft-deleted messages by result.",
+	}, []string{"result"})
+
+	purgedMessages = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "purger",
+		Name:      "purged_messages_total",
+		Help:      "Number of permanently removed soft-deleted messages.",
+	})
+
+	idempotencyPurgeRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "purger",
+		Name:      "idempotency_runs_total",
+		Help:      "Number of purge runs of expired idempotency keys by result.",
+	}, []string{"result"})
+
+	purgedIdempotencyKeys = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "purger",
+		Name:      "purged_idempotency_keys_total",
+		Help:      "Number of removed expired idempotency keys.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration, serviceOperations, serviceDuration, purgeRuns, purgedMessages,
+		idempotencyPurgeRuns, purgedIdempotencyKeys)
+}
+
+// Handler returns handler exposing all registered metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest records single handled HTTP request, route is the route template rather than the actual path
+func ObserveRequest(method string, route string, code int, duration time.Duration) {
+	codeStr := strconv.Itoa(code)
+	httpRequests.WithLabelValues(method, route, codeStr).Inc()
+	httpDuration.WithLabelValues(method, route, codeStr).Observe(duration.Seconds())
+}
+
+// ObserveOperation records single message service call started at start
+func ObserveOperation(operation string, start time.Time, err error) {
+	serviceOperations.WithLabelValues(operation, result(err)).Inc()
+	serviceDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObservePurge records single purge run of soft-deleted messages
+func ObservePurge(purged int, err error) {
+	purgeRuns.WithLabelValues(result(err)).Inc()
+	purgedMessages.Add(float64(purged))
+}
+
+// ObserveIdempotencyPurge records single purge run of expired idempotency keys
+func ObserveIdempotencyPurge(purged int, err error) {
+	idempotencyPurgeRuns.WithLabelValues(result(err)).Inc()
+	purgedIdempotencyKeys.Add(float64(purged))
+}
+
+func result(err error) string {
+	if err == nil {
+		return ResultOk
+	}
+	if msgErr, ok := err.(*ctmerror.MessageError); ok {
+		return msgErr.Error()
+	}
+	return "error"
+}
+
+// PoolStater is implemented by go-pg *pg.DB
+type PoolStater interface {
+	PoolStats() *pg.PoolStats
+}
+
+// RegisterDbPoolStats exposes connection pool statistics of the given Db, only the first
+// registered Db is exposed when several applications share the process
+func RegisterDbPoolStats(db PoolStater) error {
+	return prometheus.Register(newDbPoolCollector(db))
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/go-pg/pg"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockPoolStater struct {
+	stats pg.PoolStats
+}
+
+func (m *mockPoolStater) PoolStats() *pg.PoolStats {
+	return &m.stats
+}
+
+func TestObserveOperation(t *testing.T) {
+	// given:
+	notFoundErr := ctmerror.NewMessageError(pg.ErrNoRows)
+	okBefore := testutil.ToFloat64(serviceOperations.WithLabelValues("GetMessageById", ResultOk))
+	notFoundBefore := testutil.ToFloat64(serviceOperations.WithLabelValues("GetMessageById", notFoundErr.Error()))
+
+	// when:
+	ObserveOperation("GetMessageById", time.Now(), nil)
+	ObserveOperation("GetMessageById", time.Now(), notFoundErr)
+
+	// then:
+	assert.Equal(t, okBefore+1, testutil.ToFloat64(serviceOperations.WithLabelValues("GetMessageById", ResultOk)))
+	assert.Equal(t, notFoundBefore+1, testutil.ToFloat64(serviceOperations.WithLabelValues("GetMessageById", "error.go-example.message-not-found")))
+}
+
+func TestObserveRequest(t *testing.T) {
+	// given:
+	route := "/v1/go-example/message/{id}"
+	before := testutil.ToFloat64(httpRequests.WithLabelValues("GET", route, "404"))
+
+	// when:
+	ObserveRequest("GET", route, 404, time.Millisecond)
+
+	// then:
+	assert.Equal(t, before+1, testutil.ToFloat64(httpRequests.WithLabelValues("GET", route, "404")))
+}
+
+func TestDbPoolCollector(t *testing.T) {
+	// given:
+	collector := newDbPoolCollector(&mockPoolStater{pg.PoolStats{Hits: 5, TotalConns: 3, IdleConns: 2}})
+	expected := `
+# HELP go_example_db_pool_connections Number of total connections in the pool.
+# TYPE go_example_db_pool_connections gauge
+go_example_db_pool_connections 3
+# HELP go_example_db_pool_hits_total Number of times free connection was found in the pool.
+# TYPE go_example_db_pool_hits_total counter
+go_example_db_pool_hits_total 5
+# HELP go_example_db_pool_idle_connections Number of idle connections in the pool.
+# TYPE go_example_db_pool_idle_connections gauge
+go_example_db_pool_idle_connections 2
+`
+
+	// when:
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"go_example_db_pool_connections", "go_example_db_pool_hits_total", "go_example_db_pool_idle_connections")
+
+	// then:
+	assert.Nil(t, err)
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/auth"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "bearer "
+
+// NewAuthMiddleware returns middleware function rejecting requests without valid JWT bearer token,
+// subject and claims of the token are put into the request context and the context logger, tokens
+// with admin role mark requests as admin ones. Requests to exempt paths are passed through unauthenticated
+func NewAuthMiddleware(verifier *auth.Verifier, exempt ...string) mux.MiddlewareFunc {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header := r.Header.Get(model.HeaderKeyAuthorization)
+			if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+				unauthorized(w, r, "error.go-example.unauthorized", errors.New("bearer token is missing"), "")
+				return
+			}
+
+			claims, err := verifier.Verify(strings.TrimSpace(header[len(bearerPrefix):]))
+			if errors.Is(err, auth.ErrTokenExpired) {
+				unauthorized(w, r, "error.go-example.token-expired", err, "invalid_token")
+				return
+			} else if err != nil {
+				unauthorized(w, r, "error.go-example.unauthorized", err, "invalid_token")
+				return
+			}
+
+			subject, _ := claims["sub"].(string)
+			ctx := r.Context()
+			if logger, ok := ctx.Value(model.ContextLogger).(*log.Entry); ok {
+				ctx = context.WithValue(ctx, model.ContextLogger, logger.WithField(model.LoggerKeySubject, subject))
+			}
+			ctx = context.WithValue(ctx, model.ContextSubject, subject)
+			ctx = context.WithValue(ctx, model.ContextClaims, claims)
+			if verifier.IsAdmin(claims) {
+				ctx = context.WithValue(ctx, model.ContextAdmin, true)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// unauthorized answers with 401 problem document and the bearer challenge of RFC 6750,
+// error of the challenge is only set when a token was presented
+func unauthorized(w http.ResponseWriter, r *http.Request, errorCode string, err error, challengeError string) {
+	logger, _ := r.Context().Value(model.ContextLogger).(*log.Entry)
+	if logger == nil {
+		logger = log.NewEntry(log.StandardLogger())
+	}
+	logger.Warnf("ActionLog.Authenticate.error : Request is not authenticated, %v", err)
+
+	requestID, _ := logger.Data[model.LoggerKeyRequestID].(string)
+	problem := ctmerror.NewProblem(ctmerror.NewMessageErrorBuilder(errorCode, err, http.StatusUnauthorized),
+		r.URL.Path, requestID)
+
+	challenge := `Bearer realm="go-example"`
+	if challengeError != "" {
+		challenge += `, error="` + challengeError + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.Header().Set("Content-Type", ctmerror.ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -2,11 +2,16 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"github.com/FatimaBabayeva/ms-go-example/model"
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var headers = []string{
@@ -22,8 +27,15 @@ var headers = []string{
 	"requestid",
 }
 
-// RequestParamsMiddleware is middleware function for context time logger and header transport
-func RequestParamsMiddleware(next http.Handler) http.Handler {
+// NewRequestParamsMiddleware returns middleware function for context time logger and header transport,
+// requests carrying adminKey are marked as admin ones
+func NewRequestParamsMiddleware(adminKey string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return requestParams(next, adminKey)
+	}
+}
+
+func requestParams(next http.Handler, adminKey string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		ctx := r.Context()
@@ -41,6 +53,9 @@ func RequestParamsMiddleware(next http.Handler) http.Handler {
 		addLoggerParam(fields, model.LoggerKeyOperation, operation)
 		addLoggerParam(fields, model.LoggerKeyUserAgent, userAgent)
 		addLoggerParam(fields, model.LoggerKeyUserIP, userIP)
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+			addLoggerParam(fields, model.LoggerKeyTraceID, spanContext.TraceID().String())
+		}
 
 		logger := log.WithFields(fields)
 		header := http.Header{}
@@ -48,9 +63,12 @@ func RequestParamsMiddleware(next http.Handler) http.Handler {
 		for _, v := range headers {
 			header.Add(v, r.Header.Get(v))
 		}
+		// downstream calls continue the trace from the current server span
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
 
 		ctx = context.WithValue(ctx, model.ContextLogger, logger)
 		ctx = context.WithValue(ctx, model.ContextHeader, header)
+		ctx = context.WithValue(ctx, model.ContextAdmin, isAdmin(r.Header.Get(model.HeaderKeyAdminKey), adminKey))
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -61,3 +79,8 @@ func addLoggerParam(fields log.Fields, field string, value string) {
 		fields[field] = value
 	}
 }
+
+// isAdmin reports whether the request carries the configured admin key
+func isAdmin(key string, adminKey string) bool {
+	return len(adminKey) > 0 && subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/metrics"
+	"github.com/gorilla/mux"
+	"net/http"
+	"time"
+)
+
+// statusRecorder remembers status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// MetricsMiddleware is middleware function for counting requests and their durations per route template
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(recorder, r)
+
+		metrics.ObserveRequest(r.Method, routeTemplate(r), recorder.status, time.Since(start))
+	})
+}
+
+// routeTemplate returns path template of the matched route, so that path variables do not blow up label cardinality
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
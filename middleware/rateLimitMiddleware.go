@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"encoding/json"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/ratelimit"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewRateLimitMiddleware returns middleware function limiting requests of every client per route, clients are
+// identified by subject of the bearer token or by IP address, see client. Every response carries RateLimit-* headers,
+// requests over the limit are rejected with 429. Requests to exempt paths are not limited
+func NewRateLimitMiddleware(limiter *ratelimit.Limiter, trustedProxies int, exempt ...string) mux.MiddlewareFunc {
+	exemptPaths := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		exemptPaths[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger, _ := r.Context().Value(model.ContextLogger).(*log.Entry)
+			if logger == nil {
+				logger = log.NewEntry(log.StandardLogger())
+			}
+
+			res, limit, err := limiter.Allow(r.Context(), client(r, trustedProxies), r.Method, routeTemplate(r))
+			if err != nil {
+				// requests are not rejected because of an unavailable store
+				logger.Errorf("ActionLog.RateLimit.error : Error taking token, request is not limited, %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", ceilSeconds(res.ResetAfter))
+			w.Header().Set("RateLimit-Policy", strconv.Itoa(limit.Burst)+";w="+ceilSeconds(limit.Period))
+			if res.Allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Warnf("ActionLog.RateLimit.error : Request exceeds limit %v", limit)
+			requestID, _ := logger.Data[model.LoggerKeyRequestID].(string)
+			problem := ctmerror.NewProblem(ctmerror.NewMessageErrorBuilder("error.go-example.too-many-requests", nil,
+				http.StatusTooManyRequests), r.URL.Path, requestID)
+
+			w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+			w.Header().Set("Content-Type", ctmerror.ProblemContentType)
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(problem.Status)
+			json.NewEncoder(w).Encode(problem)
+		})
+	}
+}
+
+// client identifies the caller by subject put into the context by NewAuthMiddleware, falling back to IP address.
+// Only addresses appended to X-Forwarded-For by trustedProxies are taken, as the caller may send any of the
+// preceding ones, the address of the connection is used when there are no trusted proxies or no such header
+func client(r *http.Request, trustedProxies int) string {
+	if subject, _ := r.Context().Value(model.ContextSubject).(string); subject != "" {
+		return "sub:" + subject
+	}
+	if forwarded := r.Header[model.HeaderKeyUserIP]; trustedProxies > 0 && len(forwarded) > 0 {
+		// proxies append to the last header when the request carries several of them
+		addresses := strings.Split(strings.Join(forwarded, ","), ",")
+		i := len(addresses) - trustedProxies
+		if i < 0 {
+			i = 0
+		}
+		return "ip:" + strings.TrimSpace(addresses[i])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ceilSeconds formats duration as a number of seconds rounded up, as rate limit headers expect
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	migrate "github.com/rubenv/sql-migrate"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// migrateCommand groups subcommands managing Db schema, they let migrations run as a
+// separate job with the application started using --no-migrate
+type migrateCommand struct {
+	DryRun bool `long:"dry-run" description:"Print migrations that would be applied without applying them"`
+
+	Up     migrateUpCommand     `command:"up" description:"Apply pending migrations"`
+	Down   migrateDownCommand   `command:"down" description:"Roll back the last N applied migrations"`
+	Status migrateStatusCommand `command:"status" description:"Show applied and pending migrations"`
+	Redo   migrateRedoCommand   `command:"redo" description:"Roll back the last applied migration and apply it again"`
+}
+
+type migrateUpCommand struct {
+	Args struct {
+		N int `positional-arg-name:"N" description:"Number of migrations to apply, all pending ones by default"`
+	} `positional-args:"yes"`
+}
+
+func (c *migrateUpCommand) Execute(args []string) error {
+	if c.Args.N < 0 {
+		return errors.New("number of migrations must not be negative")
+	}
+	return runMigrations(migrate.Up, c.Args.N)
+}
+
+type migrateDownCommand struct {
+	Args struct {
+		N int `positional-arg-name:"N" description:"Number of migrations to roll back"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *migrateDownCommand) Execute(args []string) error {
+	if c.Args.N <= 0 {
+		return errors.New("number of migrations must be positive")
+	}
+	return runMigrations(migrate.Down, c.Args.N)
+}
+
+type migrateStatusCommand struct{}
+
+func (c *migrateStatusCommand) Execute(args []string) error {
+	config := loadConfig()
+	statuses, err := repo.GetMigrationStatus(config)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MIGRATION\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", s.Id, appliedAt)
+	}
+	return w.Flush()
+}
+
+type migrateRedoCommand struct{}
+
+func (c *migrateRedoCommand) Execute(args []string) error {
+	config := loadConfig()
+	planned, err := repo.PlanMigrations(config, migrate.Down, 1)
+	if err != nil {
+		return err
+	}
+	if len(planned) == 0 {
+		log.Info("No applied migrations to redo")
+		return nil
+	}
+
+	if opts.Migrate.DryRun {
+		printPlan(migrate.Down, planned)
+		printPlan(migrate.Up, []*migrate.PlannedMigration{{Migration: planned[0].Migration, Queries: planned[0].Up}})
+		return nil
+	}
+
+	if _, err := repo.ExecMigrations(config, migrate.Down, 1); err != nil {
+		return err
+	}
+	if _, err := repo.ExecMigrations(config, migrate.Up, 1); err != nil {
+		return err
+	}
+	log.Info("Migration ", planned[0].Id, " is redone")
+	return nil
+}
+
+// runMigrations applies up to max migrations in the given direction, or only prints them on dry run
+func runMigrations(dir migrate.MigrationDirection, max int) error {
+	config := loadConfig()
+
+	if opts.Migrate.DryRun {
+		planned, err := repo.PlanMigrations(config, dir, max)
+		if err != nil {
+			return err
+		}
+		printPlan(dir, planned)
+		return nil
+	}
+
+	n, err := repo.ExecMigrations(config, dir, max)
+	if err != nil {
+		return err
+	}
+	log.Info("Applied ", n, " migrations ", directionName(dir))
+	return nil
+}
+
+func printPlan(dir migrate.MigrationDirection, planned []*migrate.PlannedMigration) {
+	if len(planned) == 0 {
+		fmt.Println("-- no migrations to apply", directionName(dir))
+		return
+	}
+	for _, m := range planned {
+		fmt.Printf("-- %s %s\n", m.Id, directionName(dir))
+		for _, q := range m.Queries {
+			fmt.Println(q)
+		}
+	}
+}
+
+func directionName(dir migrate.MigrationDirection) string {
+	if dir == migrate.Down {
+		return "down"
+	}
+	return "up"
+}
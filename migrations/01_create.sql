@@ -7,3 +7,6 @@ create table if not exists message
     created_at      timestamp       not null default now(),
     updated_at      timestamp       not null default now()
 );
+
+-- +migrate Down
+drop table if exists message;
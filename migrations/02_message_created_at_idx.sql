@@ -0,0 +1,5 @@
+-- +migrate Up
+create index if not exists message_created_at_id_idx on message (created_at desc, id desc);
+
+-- +migrate Down
+drop index if exists message_created_at_id_idx;
@@ -0,0 +1,17 @@
+-- +migrate Up
+alter table message add column if not exists text_tsv tsvector;
+
+update message set text_tsv = to_tsvector('pg_catalog.simple', text);
+
+create trigger message_text_tsv_update
+    before insert or update of text on message
+    for each row execute procedure tsvector_update_trigger(text_tsv, 'pg_catalog.simple', text);
+
+create index if not exists message_text_tsv_idx on message using gin (text_tsv);
+
+-- +migrate Down
+drop index if exists message_text_tsv_idx;
+
+drop trigger if exists message_text_tsv_update on message;
+
+alter table message drop column if exists text_tsv;
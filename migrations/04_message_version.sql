@@ -0,0 +1,5 @@
+-- +migrate Up
+alter table message add column if not exists version bigint not null default 1;
+
+-- +migrate Down
+alter table message drop column if exists version;
@@ -0,0 +1,24 @@
+-- +migrate Up
+alter table message add column if not exists updated_by varchar(128) not null default '';
+
+create table if not exists message_revision
+(
+    id              bigserial       not null primary key,
+    message_id      bigint          not null references message (id) on delete cascade,
+    revision        bigint          not null,
+    text            varchar(256)    not null,
+    status          varchar(16)     not null,
+    actor           varchar(128)    not null default '',
+    created_at      timestamp       not null default now(),
+    unique (message_id, revision)
+);
+
+-- messages created before history was kept start with their current state as the only revision
+insert into message_revision (message_id, revision, text, status, created_at)
+select id, version, text, status, updated_at from message
+on conflict do nothing;
+
+-- +migrate Down
+drop table if exists message_revision;
+
+alter table message drop column if exists updated_by;
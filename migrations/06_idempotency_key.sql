@@ -0,0 +1,15 @@
+-- +migrate Up
+create table if not exists idempotency_key
+(
+    key             varchar(255)    not null primary key,
+    request_hash    varchar(64)     not null,
+    message_id      bigint          not null references message (id) on delete cascade,
+    response        jsonb           not null,
+    created_at      timestamp       not null default now(),
+    expires_at      timestamp       not null
+);
+
+create index if not exists idempotency_key_expires_at_idx on idempotency_key (expires_at);
+
+-- +migrate Down
+drop table if exists idempotency_key;
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- messages created before ownership was recorded belong to nobody and are accessible to admins only
+alter table message add column if not exists owner_id varchar(255) not null default '';
+
+create index if not exists message_owner_id_created_at_id_idx on message (owner_id, created_at desc, id desc);
+
+-- +migrate Down
+drop index if exists message_owner_id_created_at_id_idx;
+
+alter table message drop column if exists owner_id;
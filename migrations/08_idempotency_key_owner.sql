@@ -0,0 +1,18 @@
+-- +migrate Up
+-- idempotency keys are scoped to the owner of the message saved with them, so that callers cannot see
+-- or take keys of each other
+alter table idempotency_key add column if not exists owner_id varchar(255) not null default '';
+
+update idempotency_key k set owner_id = m.owner_id from message m where m.id = k.message_id;
+
+alter table idempotency_key drop constraint if exists idempotency_key_pkey;
+alter table idempotency_key add primary key (owner_id, key);
+
+-- +migrate Down
+-- keys used by several owners are kept for one of them only
+delete from idempotency_key a using idempotency_key b where a.key = b.key and a.owner_id > b.owner_id;
+
+alter table idempotency_key drop constraint if exists idempotency_key_pkey;
+alter table idempotency_key add primary key (key);
+
+alter table idempotency_key drop column if exists owner_id;
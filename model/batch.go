@@ -0,0 +1,7 @@
+package model
+
+// BatchResult is the outcome of a single item of a batch operation, Err is nil when it succeeded
+type BatchResult struct {
+	Message *Message
+	Err     error
+}
@@ -5,6 +5,9 @@ const (
 	HeaderKeyUserAgent  = "User-Agent"
 	HeaderKeyUserIP     = "X-Forwarded-For"
 	HeaderKeyRequestID  = "requestid"
+	HeaderKeyAdminKey   = "X-Admin-Key"
+	HeaderKeyIdempotencyKey = "Idempotency-Key"
+	HeaderKeyAuthorization = "Authorization"
 )
 
 // Logger additional fields key
@@ -13,6 +16,11 @@ const (
 	LoggerKeyOperation  = "OPERATION"
 	LoggerKeyUserIP     = "USER_IP"
 	LoggerKeyUserAgent  = "USER_AGENT"
+	LoggerKeyTraceID    = "TRACE_ID"
+	LoggerKeySubject    = "SUBJECT"
 	ContextLogger       = "contextLogger"
 	ContextHeader       = "contextHeader"
+	ContextAdmin        = "contextAdmin"
+	ContextSubject      = "contextSubject"
+	ContextClaims       = "contextClaims"
 )
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// MessageCursor points to the last message of a page in keyset pagination.
+// Search results are ranked by relevance and paginated by Offset instead.
+type MessageCursor struct {
+	CreatedAt time.Time `json:"c"`
+	Id        int64     `json:"i,omitempty"`
+	Offset    int       `json:"o,omitempty"`
+}
+
+// Encode returns opaque string representation of the cursor
+func (c MessageCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeMessageCursor parses cursor previously returned by Encode
+func DecodeMessageCursor(s string) (*MessageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var c MessageCursor
+	err = json.Unmarshal(b, &c)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks that the cursor was issued for the same kind of pagination, search cursors hold
+// a positive Offset only and keyset cursors hold position of a message only
+func (c MessageCursor) Validate(search bool) error {
+	if search {
+		if c.Offset <= 0 || !c.CreatedAt.IsZero() || c.Id != 0 {
+			return errors.New("cursor does not point into search results")
+		}
+		return nil
+	}
+	if c.Offset != 0 || c.CreatedAt.IsZero() || c.Id <= 0 {
+		return errors.New("cursor does not point to a message")
+	}
+	return nil
+}
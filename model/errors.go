@@ -0,0 +1,6 @@
+package model
+
+import "errors"
+
+// ErrVersionConflict is returned by repositories when the message was changed since it was read
+var ErrVersionConflict = errors.New("message version conflict")
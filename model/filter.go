@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// MessageFilter holds optional criteria for message listing and search
+type MessageFilter struct {
+	Status      MessageStatus
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	// Query is a full-text search query over message text
+	Query string
+	// IncludeDeleted makes soft-deleted messages visible, admin only
+	IncludeDeleted bool
+	// OwnerId restricts messages to the ones of a single owner, nil matches every owner
+	OwnerId *string
+}
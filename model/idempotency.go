@@ -0,0 +1,23 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyRecord is a row of idempotency_key table, it remembers the outcome of a request made
+// with Idempotency-Key header so that retries of the request get the same response until ExpiresAt.
+// Keys are scoped to OwnerId, the owner of the saved message.
+type IdempotencyRecord struct {
+	tableName struct{} `sql:"idempotency_key" pg:",discard_unknown_columns"`
+
+	OwnerId string `sql:"owner_id,pk"`
+	Key     string `sql:"key,pk"`
+	// RequestHash tells retries of the request apart from other requests reusing the key
+	RequestHash string `sql:"request_hash"`
+	MessageId   int64  `sql:"message_id"`
+	// Response is the message the request was answered with
+	Response  json.RawMessage `sql:"response"`
+	CreatedAt time.Time       `sql:"created_at"`
+	ExpiresAt time.Time       `sql:"expires_at"`
+}
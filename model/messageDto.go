@@ -0,0 +1,121 @@
+package model
+
+import "time"
+
+// CreateMessageRequest is the API payload for creating a message
+type CreateMessageRequest struct {
+	Text string `json:"text" validate:"notblank,max=256"`
+}
+
+// ToMessage maps request to a new message
+func (r CreateMessageRequest) ToMessage() Message {
+	return Message{Text: r.Text}
+}
+
+// UpdateMessageRequest is the API payload for replacing text of a message
+type UpdateMessageRequest struct {
+	Text string `json:"text" validate:"notblank,max=256"`
+}
+
+// ToMessage maps request to message holding the updated fields
+func (r UpdateMessageRequest) ToMessage() Message {
+	return Message{Text: r.Text}
+}
+
+// UpdateMessageBatchItem is an item of the API payload for updating messages in batch
+type UpdateMessageBatchItem struct {
+	Id   int64  `json:"id" validate:"required"`
+	Text string `json:"text" validate:"notblank,max=256"`
+	// Version is the expected message version like If-Match header of a single update, it is returned
+	// by every message response
+	Version int64 `json:"version" validate:"required"`
+}
+
+// ToMessage maps item to message holding its id, the updated fields and the expected version
+func (r UpdateMessageBatchItem) ToMessage() Message {
+	return Message{Id: r.Id, Text: r.Text, Version: r.Version}
+}
+
+// DeleteMessageBatchItem is an item of the API payload for deleting messages in batch
+type DeleteMessageBatchItem struct {
+	Id      int64 `json:"id" validate:"required"`
+	Version int64 `json:"version" validate:"required"`
+}
+
+// ToMessage maps item to message holding its id and the expected version
+func (r DeleteMessageBatchItem) ToMessage() Message {
+	return Message{Id: r.Id, Version: r.Version}
+}
+
+// MessageResponse is the API representation of a message
+type MessageResponse struct {
+	Id        int64         `json:"id"`
+	Text      string        `json:"text"`
+	Status    MessageStatus `json:"status"`
+	OwnerId   string        `json:"ownerId,omitempty"`
+	Version   int64         `json:"version"`
+	CreatedAt time.Time     `json:"createdAt"`
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+// NewMessageResponse maps message to its API representation
+func NewMessageResponse(m *Message) MessageResponse {
+	return MessageResponse{
+		Id:        m.Id,
+		Text:      m.Text,
+		Status:    m.Status,
+		OwnerId:   m.OwnerId,
+		Version:   m.Version,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}
+}
+
+// MessagePageResponse is the API representation of a page of messages
+type MessagePageResponse struct {
+	Items []MessageResponse `json:"items"`
+	Next  string            `json:"next,omitempty"`
+}
+
+// NewMessagePageResponse maps page of messages to its API representation
+func NewMessagePageResponse(p *MessagePage) MessagePageResponse {
+	items := make([]MessageResponse, 0, len(p.Items))
+	for i := range p.Items {
+		items = append(items, NewMessageResponse(&p.Items[i]))
+	}
+	return MessagePageResponse{Items: items, Next: p.Next}
+}
+
+// MessageRevisionResponse is the API representation of a message revision
+type MessageRevisionResponse struct {
+	Revision  int64         `json:"revision"`
+	Text      string        `json:"text"`
+	Status    MessageStatus `json:"status"`
+	Actor     string        `json:"actor"`
+	CreatedAt time.Time     `json:"createdAt"`
+}
+
+// NewMessageRevisionResponse maps message revision to its API representation
+func NewMessageRevisionResponse(r *MessageRevision) MessageRevisionResponse {
+	return MessageRevisionResponse{
+		Revision:  r.Revision,
+		Text:      r.Text,
+		Status:    r.Status,
+		Actor:     r.Actor,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// MessageRevisionListResponse is the API representation of the edit history of a message
+type MessageRevisionListResponse struct {
+	Items []MessageRevisionResponse `json:"items"`
+}
+
+// NewMessageRevisionListResponse maps message revisions to their API representation
+func NewMessageRevisionListResponse(revisions []MessageRevision) MessageRevisionListResponse {
+	items := make([]MessageRevisionResponse, 0, len(revisions))
+	for i := range revisions {
+		items = append(items, NewMessageRevisionResponse(&revisions[i]))
+	}
+	return MessageRevisionListResponse{Items: items}
+}
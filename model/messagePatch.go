@@ -0,0 +1,28 @@
+package model
+
+// PatchType is the media type of a patch document
+type PatchType string
+
+const (
+	// MergePatch is JSON Merge Patch, RFC 7396
+	MergePatch PatchType = "application/merge-patch+json"
+	// JSONPatch is JSON Patch, RFC 6902
+	JSONPatch PatchType = "application/json-patch+json"
+)
+
+// IsSupported reports whether the patch type is one of the known patch formats
+func (t PatchType) IsSupported() bool {
+	return t == MergePatch || t == JSONPatch
+}
+
+// MessagePatch is a partial update of a message in one of the supported patch formats
+type MessagePatch struct {
+	Type     PatchType
+	Document []byte
+}
+
+// PatchableMessage is the document patches are applied to, it holds the fields clients may change
+type PatchableMessage struct {
+	Text   string        `json:"text" validate:"notblank,max=256"`
+	Status MessageStatus `json:"status" validate:"oneof=CREATED DELETED"`
+}
@@ -6,3 +6,12 @@ const (
 	CREATED MessageStatus = "CREATED"
 	DELETED MessageStatus = "DELETED"
 )
+
+// IsValid reports whether status is one of the known message statuses
+func (s MessageStatus) IsValid() bool {
+	switch s {
+	case CREATED, DELETED:
+		return true
+	}
+	return false
+}
@@ -2,12 +2,24 @@ package model
 
 import "time"
 
+// Message is a row of message table, see messageDto.go for its API representation
 type Message struct {
 	tableName struct{} `sql:"message" pg:",discard_unknown_columns"`
 
-	Id        int64         `sql:"id,pk" json:"id"`
-	Text      string        `sql:"text" json:"text"`
-	Status    MessageStatus `sql:"status" json:"status"`
-	CreatedAt time.Time     `sql:"created_at" json:"-"`
-	UpdatedAt time.Time     `sql:"updated_at" json:"-"`
+	Id        int64         `sql:"id,pk"`
+	Text      string        `sql:"text"`
+	Status    MessageStatus `sql:"status"`
+	CreatedAt time.Time     `sql:"created_at,type:timestamp"`
+	UpdatedAt time.Time     `sql:"updated_at,type:timestamp"`
+	UpdatedBy string        `sql:"updated_by,notnull"`
+	// OwnerId is the subject who created the message, only the owner and admins may access it
+	OwnerId string `sql:"owner_id,notnull"`
+	// Version is incremented on every update and exposed to clients as ETag
+	Version int64 `sql:"version"`
+}
+
+// MessagePage is a single page of messages with a cursor pointing to the next one
+type MessagePage struct {
+	Items []Message
+	Next  string
 }
@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Actors recorded in message history for requests without bearer token subject
+const (
+	ActorAdmin     = "admin"
+	ActorAnonymous = "anonymous"
+)
+
+// MessageRevision is a row of message_revision table, a snapshot of a message taken on every change
+type MessageRevision struct {
+	tableName struct{} `sql:"message_revision" pg:",discard_unknown_columns"`
+
+	Id        int64 `sql:"id,pk"`
+	MessageId int64 `sql:"message_id"`
+	// Revision is the message version the snapshot was taken at
+	Revision  int64         `sql:"revision"`
+	Text      string        `sql:"text"`
+	Status    MessageStatus `sql:"status"`
+	Actor     string        `sql:"actor,notnull"`
+	CreatedAt time.Time     `sql:"created_at"`
+}
+
+// NewMessageRevision takes a snapshot of the current state of message
+func NewMessageRevision(m *Message) *MessageRevision {
+	return &MessageRevision{
+		MessageId: m.Id,
+		Revision:  m.Version,
+		Text:      m.Text,
+		Status:    m.Status,
+		Actor:     m.UpdatedBy,
+		CreatedAt: m.UpdatedAt,
+	}
+}
@@ -1,27 +1,96 @@
 package properties
 
-import "github.com/alexflint/go-arg"
+import (
+	"github.com/alexflint/go-arg"
+	"time"
+)
 
 // RootPath is project root path
 const RootPath = "/v1/go-example"
 
-type args struct {
+// Config holds service configuration read from environment
+type Config struct {
 	LogLevel string `arg:"env:LOG_LEVEL"`
 	Port     int    `arg:"env:PORT"`
 	DbUrl    string `arg:"env:DB_URL"`
 	DbUser   string `arg:"env:DB_USER"`
 	DbPass   string `arg:"env:DB_PASS"`
+	AdminKey string `arg:"env:ADMIN_KEY"`
+
+	// AuthEnabled requires JWT bearer token on every route except health checks and metrics
+	AuthEnabled bool `arg:"env:AUTH_ENABLED"`
+	// Tokens are signed with HS256 using AuthHmacSecret or with RS256 using PEM encoded AuthRsaPublicKey
+	// or one of the keys in AuthJwksFile
+	AuthHmacSecret   string `arg:"env:AUTH_HMAC_SECRET"`
+	AuthRsaPublicKey string `arg:"env:AUTH_RSA_PUBLIC_KEY"`
+	AuthJwksFile     string `arg:"env:AUTH_JWKS_FILE"`
+	// AuthIssuer and AuthAudience are checked only when set
+	AuthIssuer   string `arg:"env:AUTH_ISSUER"`
+	AuthAudience string `arg:"env:AUTH_AUDIENCE"`
+	// AuthAdminRole in roles claim of the token grants the same permissions as AdminKey
+	AuthAdminRole string `arg:"env:AUTH_ADMIN_ROLE"`
+
+	// Storage is postgres or memory, the latter keeps messages only until the application stops
+	Storage string `arg:"env:STORAGE"`
+	// DbTimeout bounds every query or transaction issued while serving a request, 0 disables it
+	DbTimeout time.Duration `arg:"env:DB_TIMEOUT"`
+	// MigrateOnStart applies pending migrations at startup, disable it when they run as a separate job
+	MigrateOnStart bool `arg:"env:MIGRATE_ON_START"`
+
+	HttpReadTimeout  time.Duration `arg:"env:HTTP_READ_TIMEOUT"`
+	HttpWriteTimeout time.Duration `arg:"env:HTTP_WRITE_TIMEOUT"`
+	HttpIdleTimeout  time.Duration `arg:"env:HTTP_IDLE_TIMEOUT"`
+	// ShutdownDrain is how long readiness reports not-ready before the server stops accepting requests
+	ShutdownDrain time.Duration `arg:"env:SHUTDOWN_DRAIN"`
+	// ShutdownTimeout bounds waiting for in-flight requests to complete
+	ShutdownTimeout time.Duration `arg:"env:SHUTDOWN_TIMEOUT"`
+	// HealthCheckTimeout bounds every single readiness check
+	HealthCheckTimeout time.Duration `arg:"env:HEALTH_CHECK_TIMEOUT"`
+
+	// TracingExporter is one of none, stdout or otlp
+	TracingExporter    string  `arg:"env:TRACING_EXPORTER"`
+	TracingEndpoint    string  `arg:"env:TRACING_ENDPOINT"`
+	TracingInsecure    bool    `arg:"env:TRACING_INSECURE"`
+	TracingSampleRatio float64 `arg:"env:TRACING_SAMPLE_RATIO"`
+
+	// RateLimit is written as count/period, e.g. 100/m, and applies to every client on every route
+	// missing in RateLimitRoutes, empty one disables rate limiting. RateLimitRoutes are comma separated
+	// "METHOD path-template=limit" entries.
+	RateLimit       string `arg:"env:RATE_LIMIT"`
+	RateLimitRoutes string `arg:"env:RATE_LIMIT_ROUTES"`
+	// RateLimitByIP applies to every address across all routes together before authentication, so that
+	// requests rejected by it are limited too, empty one disables it
+	RateLimitByIP string `arg:"env:RATE_LIMIT_BY_IP"`
+	// RateLimitTrustedProxies is the number of proxies in front of the service appending to X-Forwarded-For,
+	// 0 identifies clients by the address of the connection
+	RateLimitTrustedProxies int `arg:"env:RATE_LIMIT_TRUSTED_PROXIES"`
+
+	// IdempotencyTTL is how long a message saved with Idempotency-Key is returned to retries of the request
+	IdempotencyTTL time.Duration `arg:"env:IDEMPOTENCY_TTL"`
+	// Expired idempotency keys are removed every IdempotencyPurgeInterval in batches of PurgeBatchSize,
+	// 0 disables removal
+	IdempotencyPurgeInterval time.Duration `arg:"env:IDEMPOTENCY_PURGE_INTERVAL"`
+
+	// Soft-deleted messages older than PurgeRetention are removed permanently, 0 disables purging
+	PurgeRetention time.Duration `arg:"env:PURGE_RETENTION"`
+	PurgeInterval  time.Duration `arg:"env:PURGE_INTERVAL"`
+	PurgeBatchSize int           `arg:"env:PURGE_BATCH_SIZE"`
 }
 
+// Storage kinds
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
 // DbConnStr constructs connection string from env variables
-func (p *args) DbConnStr() string {
-	return "postgres://" + p.DbUser + ":" + p.DbPass + "@" + p.DbUrl
+func (c Config) DbConnStr() string {
+	return "postgres://" + c.DbUser + ":" + c.DbPass + "@" + c.DbUrl
 }
 
-// Props is for storing environment properties
-var Props args
-
-// LoadConfig loads service configuration into environment
-func LoadConfig() {
-	arg.Parse(&Props)
+// LoadConfig reads service configuration from environment
+func LoadConfig() Config {
+	var config Config
+	arg.Parse(&config)
+	return config
 }
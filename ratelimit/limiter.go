@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Limiter gives every client a separate token bucket per route, or a single one shared by all routes
+type Limiter struct {
+	store Store
+	// limit applies to routes missing in routes, which are keyed by method and path template
+	limit    Limit
+	routes   map[string]Limit
+	perRoute bool
+}
+
+// NewLimiter builds limiter from the default limit and route limits, which are comma separated
+// "METHOD path-template=limit" entries, e.g. "POST /v1/go-example/message/batch=10/m"
+func NewLimiter(store Store, limit string, routes string) (*Limiter, error) {
+	l := &Limiter{store: store, routes: map[string]Limit{}, perRoute: true}
+
+	var err error
+	if l.limit, err = ParseLimit(limit); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range strings.Split(routes, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, "=")
+		var route []string
+		if i >= 0 {
+			route = strings.Fields(entry[:i])
+		}
+		if len(route) != 2 {
+			return nil, fmt.Errorf("route limit %q is not written as METHOD path-template=limit", entry)
+		}
+		routeLimit, err := ParseLimit(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		l.routes[routeKey(route[0], route[1])] = routeLimit
+	}
+	return l, nil
+}
+
+// NewClientLimiter builds limiter giving every client a single bucket shared by all routes
+func NewClientLimiter(store Store, limit string) (*Limiter, error) {
+	parsed, err := ParseLimit(limit)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{store: store, limit: parsed}, nil
+}
+
+// Allow takes a token from the bucket of the client for the route, which is identified by method and
+// path template. The limit applied to the route is returned along with the result.
+func (l *Limiter) Allow(ctx context.Context, client string, method string, route string) (Result, Limit, error) {
+	if !l.perRoute {
+		res, err := l.store.Take(ctx, client, l.limit)
+		return res, l.limit, err
+	}
+
+	key := routeKey(method, route)
+	limit, ok := l.routes[key]
+	if !ok {
+		limit = l.limit
+	}
+
+	res, err := l.store.Take(ctx, client+"|"+key, limit)
+	return res, limit, err
+}
+
+func routeKey(method string, route string) string {
+	return strings.ToUpper(method) + " " + route
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket holding up to Burst tokens, which is refilled with Burst tokens every Period
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses limit written as count/period, e.g. 100/m or 10/30s
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("limit %q is not written as count/period", s)
+	}
+
+	burst, err := strconv.Atoi(parts[0])
+	if err != nil || burst <= 0 {
+		return Limit{}, fmt.Errorf("limit %q must have positive count", s)
+	}
+
+	period := parts[1]
+	if period != "" && strings.IndexAny(period[:1], "0123456789") < 0 {
+		// a bare unit stands for a single one of it
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("limit %q must have positive period", s)
+	}
+	return Limit{Burst: burst, Period: d}, nil
+}
+
+func (l Limit) String() string {
+	return strconv.Itoa(l.Burst) + "/" + l.Period.String()
+}
+
+// rate is the number of tokens added to the bucket per second
+func (l Limit) rate() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// Result describes the bucket after an attempt to take a token from it
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is how long to wait for the next token, zero when a token was taken
+	RetryAfter time.Duration
+	// ResetAfter is how long it takes the bucket to fill up
+	ResetAfter time.Duration
+}
+
+// Store keeps token buckets, a store shared between instances of the service lets them enforce common limits
+type Store interface {
+	// Take removes a token from the bucket identified by key, a missing bucket is created full
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		limit    string
+		expected Limit
+		valid    bool
+	}{
+		{"100/m", Limit{Burst: 100, Period: time.Minute}, true},
+		{"10/30s", Limit{Burst: 10, Period: 30 * time.Second}, true},
+		{" 5/1h ", Limit{Burst: 5, Period: time.Hour}, true},
+		{"100", Limit{}, false},
+		{"0/s", Limit{}, false},
+		{"10/", Limit{}, false},
+		{"10/-1s", Limit{}, false},
+		{"ten/s", Limit{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.limit, func(t *testing.T) {
+			// when:
+			result, err := ParseLimit(tt.limit)
+
+			// then:
+			assert.Equal(t, tt.valid, err == nil)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	// given:
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	limit := Limit{Burst: 2, Period: 2 * time.Second}
+
+	// when:
+	first, _ := s.Take(context.Background(), "key", limit)
+	second, _ := s.Take(context.Background(), "key", limit)
+	rejected, _ := s.Take(context.Background(), "key", limit)
+	other, _ := s.Take(context.Background(), "other", limit)
+	now = now.Add(time.Second)
+	refilled, _ := s.Take(context.Background(), "key", limit)
+
+	// then:
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, ResetAfter: time.Second}, first)
+	assert.Equal(t, Result{Allowed: true, Remaining: 0, ResetAfter: 2 * time.Second}, second)
+	assert.Equal(t, Result{Allowed: false, Remaining: 0, RetryAfter: time.Second, ResetAfter: 2 * time.Second}, rejected)
+	assert.True(t, other.Allowed)
+	assert.True(t, refilled.Allowed)
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	// given:
+	now := time.Now()
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	s.Take(context.Background(), "idle", Limit{Burst: 1, Period: time.Second})
+	s.Take(context.Background(), "busy", Limit{Burst: 10, Period: time.Hour})
+
+	// when:
+	now = now.Add(sweepInterval)
+	s.Take(context.Background(), "new", Limit{Burst: 1, Period: time.Second})
+
+	// then:
+	assert.NotContains(t, s.buckets, "idle")
+	assert.Contains(t, s.buckets, "busy")
+	assert.Contains(t, s.buckets, "new")
+}
+
+func TestNewLimiter_RouteLimits(t *testing.T) {
+	// given:
+	l, err := NewLimiter(NewMemoryStore(), "100/m", "POST /message/batch=1/m, GET /message/{id}=5/s")
+	assert.Nil(t, err)
+
+	// when:
+	_, batchLimit, _ := l.Allow(context.Background(), "client", "POST", "/message/batch")
+	rejected, _, _ := l.Allow(context.Background(), "client", "POST", "/message/batch")
+	otherClient, _, _ := l.Allow(context.Background(), "other", "POST", "/message/batch")
+	_, getLimit, _ := l.Allow(context.Background(), "client", "GET", "/message/{id}")
+	_, defaultLimit, _ := l.Allow(context.Background(), "client", "PUT", "/message/{id}")
+
+	// then:
+	assert.Equal(t, Limit{Burst: 1, Period: time.Minute}, batchLimit)
+	assert.False(t, rejected.Allowed)
+	assert.True(t, otherClient.Allowed)
+	assert.Equal(t, Limit{Burst: 5, Period: time.Second}, getLimit)
+	assert.Equal(t, Limit{Burst: 100, Period: time.Minute}, defaultLimit)
+}
+
+func TestNewClientLimiter_SharedByRoutes(t *testing.T) {
+	// given:
+	l, err := NewClientLimiter(NewMemoryStore(), "2/m")
+	assert.Nil(t, err)
+
+	// when:
+	first, _, _ := l.Allow(context.Background(), "client", "GET", "/message/{id}")
+	second, _, _ := l.Allow(context.Background(), "client", "PUT", "/message/{id}")
+	rejected, limit, _ := l.Allow(context.Background(), "client", "POST", "/message")
+	otherClient, _, _ := l.Allow(context.Background(), "other", "POST", "/message")
+
+	// then:
+	assert.True(t, first.Allowed)
+	assert.True(t, second.Allowed)
+	assert.False(t, rejected.Allowed)
+	assert.Equal(t, Limit{Burst: 2, Period: time.Minute}, limit)
+	assert.True(t, otherClient.Allowed)
+}
+
+func TestNewLimiter_Invalid(t *testing.T) {
+	for _, routes := range []string{"/message=1/m", "POST /message", "POST /message=1"} {
+		// when:
+		_, err := NewLimiter(NewMemoryStore(), "100/m", routes)
+
+		// then:
+		assert.NotNil(t, err, routes)
+	}
+}
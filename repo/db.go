@@ -1,46 +1,151 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"github.com/FatimaBabayeva/ms-go-example/properties"
+	"github.com/FatimaBabayeva/ms-go-example/tracing"
 	"github.com/go-pg/pg"
 	_ "github.com/lib/pq"
 	migrate "github.com/rubenv/sql-migrate"
 	log "github.com/sirupsen/logrus"
 	"strings"
+	"time"
 )
 
-var Db *pg.DB
+var migrationSource = &migrate.FileMigrationSource{
+	Dir: "migrations",
+}
 
-func InitDb() {
-	Db = pg.Connect(&pg.Options{
-		Addr:     strings.Split(properties.Props.DbUrl, "/")[0],
-		Database: strings.Split(properties.Props.DbUrl, "/")[1],
-		User:     properties.Props.DbUser,
-		Password: properties.Props.DbPass,
+// NewDb creates connection pool to Db described by config
+func NewDb(config properties.Config) *pg.DB {
+	db := pg.Connect(&pg.Options{
+		Addr:     strings.Split(config.DbUrl, "/")[0],
+		Database: strings.Split(config.DbUrl, "/")[1],
+		User:     config.DbUser,
+		Password: config.DbPass,
 	})
+	db.AddQueryHook(tracing.QueryHook{})
+	return db
 }
 
-func MigrateDb() error {
+// MigrateDb applies all pending migrations
+func MigrateDb(config properties.Config) error {
 	log.Info("MigrateDb.start")
 
-	connStr := properties.Props.DbConnStr() + "?sslmode=disable"
-	db, err := sql.Open("postgres", connStr)
+	n, err := ExecMigrations(config, migrate.Up, 0)
 	if err != nil {
 		return err
 	}
+
+	log.Info("Applied ", n, " migrations")
+	log.Info("MigrateDb.end")
+	return nil
+}
+
+// ExecMigrations applies up to max migrations in the given direction, 0 applies all of them
+func ExecMigrations(config properties.Config, dir migrate.MigrationDirection, max int) (int, error) {
+	db, err := openMigrationDb(config)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	return migrate.ExecMax(db, "postgres", migrationSource, dir, max)
+}
+
+// PlanMigrations returns migrations ExecMigrations would apply, without applying them
+func PlanMigrations(config properties.Config, dir migrate.MigrationDirection, max int) ([]*migrate.PlannedMigration, error) {
+	db, err := openMigrationDb(config)
+	if err != nil {
+		return nil, err
+	}
 	defer db.Close()
 
-	migrations := &migrate.FileMigrationSource{
-		Dir: "migrations",
+	planned, _, err := migrate.PlanMigration(db, "postgres", migrationSource, dir, max)
+	return planned, err
+}
+
+// MigrationStatus tells whether a migration is applied to Db, AppliedAt is nil for pending ones
+type MigrationStatus struct {
+	Id        string
+	AppliedAt *time.Time
+}
+
+// GetMigrationStatus returns status of every migration from migrations directory, in the order they apply
+func GetMigrationStatus(config properties.Config) ([]MigrationStatus, error) {
+	migrations, err := migrationSource.FindMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := openMigrationDb(config)
+	if err != nil {
+		return nil, err
 	}
+	defer db.Close()
 
-	n, err := migrate.Exec(db, "postgres", migrations, migrate.Up)
+	records, err := migrate.GetMigrationRecords(db, "postgres")
+	if err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[string]time.Time, len(records))
+	for _, r := range records {
+		appliedAt[r.Id] = r.AppliedAt
+	}
+
+	res := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Id: m.Id}
+		if t, ok := appliedAt[m.Id]; ok {
+			status.AppliedAt = &t
+		}
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+func openMigrationDb(config properties.Config) (*sql.DB, error) {
+	connStr := config.DbConnStr() + "?sslmode=disable"
+	return sql.Open("postgres", connStr)
+}
+
+// PingDb checks that Db accepts queries
+func PingDb(ctx context.Context, db *pg.DB) error {
+	_, err := db.WithContext(ctx).Exec("SELECT 1")
+	return err
+}
+
+// CheckMigrations checks that every migration from migrations directory is applied to Db
+func CheckMigrations(ctx context.Context, db *pg.DB) error {
+	migrations, err := migrationSource.FindMigrations()
 	if err != nil {
 		return err
 	}
 
-	log.Info("Applied ", n, " migrations")
-	log.Info("MigrateDb.end")
+	var applied []struct {
+		Id string
+	}
+	_, err = db.WithContext(ctx).Query(&applied, "SELECT id FROM gorp_migrations")
+	if err != nil {
+		return err
+	}
+
+	appliedIds := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		appliedIds[m.Id] = true
+	}
+
+	pending := make([]string, 0)
+	for _, m := range migrations {
+		if !appliedIds[m.Id] {
+			pending = append(pending, m.Id)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %v", pending)
+	}
 	return nil
 }
@@ -0,0 +1,553 @@
+package repo
+
+import (
+	"context"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/go-pg/pg"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MemoryMessageRepo is a thread-safe MessageRepo keeping messages in memory, it is meant
+// for local runs and tests. Missing messages are reported with pg.ErrNoRows like in Db.
+type MemoryMessageRepo struct {
+	mu        sync.RWMutex
+	nextId    int64
+	messages  map[int64]model.Message
+	revisions map[int64][]model.MessageRevision
+	keys      map[idempotencyKey]model.IdempotencyRecord
+
+	// txMu serializes transactions and changes of existing entries made outside of them, see RunInTx
+	txMu sync.Mutex
+}
+
+// idempotencyKey identifies idempotency record, keys are scoped to the owner
+type idempotencyKey struct {
+	ownerId string
+	key     string
+}
+
+// NewMemoryMessageRepo returns empty in-memory MessageRepo
+func NewMemoryMessageRepo() *MemoryMessageRepo {
+	return &MemoryMessageRepo{
+		nextId:    1,
+		messages:  make(map[int64]model.Message),
+		revisions: make(map[int64][]model.MessageRevision),
+		keys:      make(map[idempotencyKey]model.IdempotencyRecord),
+	}
+}
+
+// RunInTx runs transactions one at a time and undoes changes made by fn when it fails.
+// Outside of transactions only saving of new messages does not wait for the running one,
+// it never touches entries the transaction changed. Like Db sequences, ids are not reused.
+func (r *MemoryMessageRepo) RunInTx(ctx context.Context, fn func(MessageRepo) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	tx := memoryTx{MemoryMessageRepo: r, undo: newUndoLog()}
+	if err := fn(tx); err != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		tx.undo.rollback(r)
+		return err
+	}
+	return nil
+}
+
+// memoryTx is MemoryMessageRepo bound to a running transaction, its changes are recorded in the
+// undo log. Nested RunInTx joins the transaction.
+type memoryTx struct {
+	*MemoryMessageRepo
+	undo *undoLog
+}
+
+func (tx memoryTx) RunInTx(ctx context.Context, fn func(MessageRepo) error) error {
+	return fn(tx)
+}
+
+func (tx memoryTx) Save(ctx context.Context, m *model.Message) (*model.Message, error) {
+	return m, tx.saveAll(ctx, []*model.Message{m}, tx.undo)
+}
+
+func (tx memoryTx) SaveAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	return ms, tx.saveAll(ctx, pointers(ms), tx.undo)
+}
+
+func (tx memoryTx) Update(ctx context.Context, m *model.Message) (*model.Message, error) {
+	return m, tx.updateAll(ctx, []*model.Message{m}, tx.undo)
+}
+
+func (tx memoryTx) UpdateAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	return ms, tx.updateAll(ctx, pointers(ms), tx.undo)
+}
+
+func (tx memoryTx) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	return tx.purgeDeleted(ctx, before, limit, tx.undo)
+}
+
+func (tx memoryTx) SaveIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	return tx.saveIdempotencyRecord(ctx, record, tx.undo)
+}
+
+func (tx memoryTx) PurgeIdempotencyRecords(ctx context.Context, before time.Time, limit int) (int, error) {
+	return tx.purgeIdempotencyRecords(ctx, before, limit, tx.undo)
+}
+
+// undoLog holds entries changed by a transaction as they were before their first change,
+// nil ones were missing. Outside of transactions the log is nil and records nothing.
+type undoLog struct {
+	messages  map[int64]*model.Message
+	revisions map[int64][]model.MessageRevision
+	keys      map[idempotencyKey]*model.IdempotencyRecord
+}
+
+func newUndoLog() *undoLog {
+	return &undoLog{
+		messages:  make(map[int64]*model.Message),
+		revisions: make(map[int64][]model.MessageRevision),
+		keys:      make(map[idempotencyKey]*model.IdempotencyRecord),
+	}
+}
+
+// message records the message and its revisions before they are changed, r.mu must be held
+func (u *undoLog) message(r *MemoryMessageRepo, id int64) {
+	if u == nil {
+		return
+	}
+	if _, ok := u.messages[id]; ok {
+		return
+	}
+	if m, ok := r.messages[id]; ok {
+		u.messages[id] = &m
+	} else {
+		u.messages[id] = nil
+	}
+	u.revisions[id] = append([]model.MessageRevision(nil), r.revisions[id]...)
+}
+
+// key records the idempotency record before it is changed, r.mu must be held
+func (u *undoLog) key(r *MemoryMessageRepo, key idempotencyKey) {
+	if u == nil {
+		return
+	}
+	if _, ok := u.keys[key]; ok {
+		return
+	}
+	if record, ok := r.keys[key]; ok {
+		u.keys[key] = &record
+	} else {
+		u.keys[key] = nil
+	}
+}
+
+// rollback puts the recorded entries back, r.mu must be held
+func (u *undoLog) rollback(r *MemoryMessageRepo) {
+	for id, m := range u.messages {
+		if m == nil {
+			delete(r.messages, id)
+		} else {
+			r.messages[id] = *m
+		}
+	}
+	for id, revisions := range u.revisions {
+		if len(revisions) == 0 {
+			delete(r.revisions, id)
+		} else {
+			r.revisions[id] = revisions
+		}
+	}
+	for key, record := range u.keys {
+		if record == nil {
+			delete(r.keys, key)
+		} else {
+			r.keys[key] = *record
+		}
+	}
+}
+
+func pointers(ms []model.Message) []*model.Message {
+	res := make([]*model.Message, len(ms))
+	for i := range ms {
+		res[i] = &ms[i]
+	}
+	return res
+}
+
+// Save assigns the next id to message, timestamps left zero are set like Db defaults do
+func (r *MemoryMessageRepo) Save(ctx context.Context, m *model.Message) (*model.Message, error) {
+	return m, r.saveAll(ctx, []*model.Message{m}, nil)
+}
+
+func (r *MemoryMessageRepo) SaveAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	return ms, r.saveAll(ctx, pointers(ms), nil)
+}
+
+func (r *MemoryMessageRepo) saveAll(ctx context.Context, ms []*model.Message, u *undoLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, m := range ms {
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = now
+		}
+		if m.UpdatedAt.IsZero() {
+			m.UpdatedAt = now
+		}
+		m.Id = r.nextId
+		r.nextId++
+		u.message(r, m.Id)
+		r.messages[m.Id] = *m
+		r.addRevision(m)
+	}
+	return nil
+}
+
+// Update saves message only if its version is unchanged, incrementing the version
+func (r *MemoryMessageRepo) Update(ctx context.Context, m *model.Message) (*model.Message, error) {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	return m, r.updateAll(ctx, []*model.Message{m}, nil)
+}
+
+// UpdateAll saves messages only if none of them changed, incrementing their versions
+func (r *MemoryMessageRepo) UpdateAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	return ms, r.updateAll(ctx, pointers(ms), nil)
+}
+
+func (r *MemoryMessageRepo) updateAll(ctx context.Context, ms []*model.Message, u *undoLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range ms {
+		if stored, ok := r.messages[m.Id]; !ok || stored.Version != m.Version {
+			return model.ErrVersionConflict
+		}
+	}
+	for _, m := range ms {
+		m.Version++
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = r.messages[m.Id].CreatedAt
+		}
+		u.message(r, m.Id)
+		r.messages[m.Id] = *m
+		r.addRevision(m)
+	}
+	return nil
+}
+
+func (r *MemoryMessageRepo) addRevision(m *model.Message) {
+	revision := model.NewMessageRevision(m)
+	revision.Id = int64(len(r.revisions[m.Id]) + 1)
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+	r.revisions[m.Id] = append(r.revisions[m.Id], *revision)
+}
+
+func (r *MemoryMessageRepo) Get(ctx context.Context, id int64) (*model.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := r.messages[id]
+	if !ok {
+		return nil, pg.ErrNoRows
+	}
+	return &m, nil
+}
+
+// GetForUpdate is Get, rows need no locking as transactions do not run concurrently
+func (r *MemoryMessageRepo) GetForUpdate(ctx context.Context, id int64) (*model.Message, error) {
+	return r.Get(ctx, id)
+}
+
+// GetAllForUpdate returns existing messages with the given ids ordered by id
+func (r *MemoryMessageRepo) GetAllForUpdate(ctx context.Context, ids []int64) ([]model.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	res := r.filter(func(m *model.Message) bool {
+		return wanted[m.Id]
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+	return res, nil
+}
+
+// List returns up to limit filtered messages ordered from newest to oldest, starting right after the given cursor
+func (r *MemoryMessageRepo) List(ctx context.Context, filter model.MessageFilter, after *model.MessageCursor, limit int) ([]model.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	res := r.filter(func(m *model.Message) bool {
+		if !matchesFilter(m, filter) {
+			return false
+		}
+		return after == nil || m.CreatedAt.Before(after.CreatedAt) ||
+			(m.CreatedAt.Equal(after.CreatedAt) && m.Id < after.Id)
+	})
+	sort.Slice(res, func(i, j int) bool {
+		if !res[i].CreatedAt.Equal(res[j].CreatedAt) {
+			return res[i].CreatedAt.After(res[j].CreatedAt)
+		}
+		return res[i].Id > res[j].Id
+	})
+	return page(res, 0, limit), nil
+}
+
+// Search returns filtered messages containing every word of filter.Query, newest first.
+// Unlike Db it does not rank messages by relevance.
+func (r *MemoryMessageRepo) Search(ctx context.Context, filter model.MessageFilter, offset int, limit int) ([]model.Message, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	query := words(filter.Query)
+	res := r.filter(func(m *model.Message) bool {
+		if !matchesFilter(m, filter) || len(query) == 0 {
+			return false
+		}
+		text := make(map[string]bool)
+		for _, w := range words(m.Text) {
+			text[w] = true
+		}
+		for _, w := range query {
+			if !text[w] {
+				return false
+			}
+		}
+		return true
+	})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id > res[j].Id
+	})
+	return page(res, offset, limit), nil
+}
+
+// PurgeDeleted permanently removes up to limit messages soft-deleted before the given time
+func (r *MemoryMessageRepo) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	return r.purgeDeleted(ctx, before, limit, nil)
+}
+
+func (r *MemoryMessageRepo) purgeDeleted(ctx context.Context, before time.Time, limit int, u *undoLog) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]int64, 0)
+	for id, m := range r.messages {
+		if m.Status == model.DELETED && m.UpdatedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+
+	for _, id := range ids {
+		u.message(r, id)
+		delete(r.messages, id)
+		delete(r.revisions, id)
+		for key, record := range r.keys {
+			if record.MessageId == id {
+				u.key(r, key)
+				delete(r.keys, key)
+			}
+		}
+	}
+	return len(ids), nil
+}
+
+// ListRevisions returns all revisions of the message, oldest first
+func (r *MemoryMessageRepo) ListRevisions(ctx context.Context, messageId int64) ([]model.MessageRevision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append(make([]model.MessageRevision, 0), r.revisions[messageId]...), nil
+}
+
+func (r *MemoryMessageRepo) GetRevision(ctx context.Context, messageId int64, revision int64) (*model.MessageRevision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rev := range r.revisions[messageId] {
+		if rev.Revision == revision {
+			return &rev, nil
+		}
+	}
+	return nil, pg.ErrNoRows
+}
+
+func (r *MemoryMessageRepo) GetIdempotencyRecord(ctx context.Context, ownerId string, key string) (*model.IdempotencyRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	record, ok := r.keys[idempotencyKey{ownerId, key}]
+	if !ok || !record.ExpiresAt.After(time.Now()) {
+		return nil, pg.ErrNoRows
+	}
+	return &record, nil
+}
+
+// SaveIdempotencyRecord stores record replacing an expired one with the same owner and key
+func (r *MemoryMessageRepo) SaveIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	return r.saveIdempotencyRecord(ctx, record, nil)
+}
+
+func (r *MemoryMessageRepo) saveIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord, u *undoLog) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now()
+	}
+	key := idempotencyKey{record.OwnerId, record.Key}
+	if stored, ok := r.keys[key]; ok && stored.ExpiresAt.After(record.CreatedAt) {
+		return ErrIdempotencyKeyExists
+	}
+	u.key(r, key)
+	r.keys[key] = *record
+	return nil
+}
+
+// PurgeIdempotencyRecords removes up to limit records expired before the given time
+func (r *MemoryMessageRepo) PurgeIdempotencyRecords(ctx context.Context, before time.Time, limit int) (int, error) {
+	r.txMu.Lock()
+	defer r.txMu.Unlock()
+
+	return r.purgeIdempotencyRecords(ctx, before, limit, nil)
+}
+
+func (r *MemoryMessageRepo) purgeIdempotencyRecords(ctx context.Context, before time.Time, limit int, u *undoLog) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for key, record := range r.keys {
+		if n == limit {
+			break
+		}
+		if record.ExpiresAt.Before(before) {
+			u.key(r, key)
+			delete(r.keys, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// filter returns copies of messages accepted by match
+func (r *MemoryMessageRepo) filter(match func(m *model.Message) bool) []model.Message {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := make([]model.Message, 0)
+	for _, m := range r.messages {
+		if match(&m) {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+func matchesFilter(m *model.Message, filter model.MessageFilter) bool {
+	switch {
+	case !filter.IncludeDeleted && m.Status == model.DELETED:
+		return false
+	case filter.Status != "" && m.Status != filter.Status:
+		return false
+	case filter.CreatedFrom != nil && m.CreatedAt.Before(*filter.CreatedFrom):
+		return false
+	case filter.CreatedTo != nil && !m.CreatedAt.Before(*filter.CreatedTo):
+		return false
+	case filter.UpdatedFrom != nil && m.UpdatedAt.Before(*filter.UpdatedFrom):
+		return false
+	case filter.UpdatedTo != nil && !m.UpdatedAt.Before(*filter.UpdatedTo):
+		return false
+	case filter.OwnerId != nil && m.OwnerId != *filter.OwnerId:
+		return false
+	}
+	return true
+}
+
+func page(messages []model.Message, offset int, limit int) []model.Message {
+	if offset >= len(messages) {
+		return messages[:0]
+	}
+	if offset > 0 {
+		messages = messages[offset:]
+	}
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages
+}
+
+// words splits text into lower case words the way the simple text search configuration does
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,283 @@
+package repo
+
+import (
+	"context"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/go-pg/pg"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func saveMessages(t *testing.T, r *MemoryMessageRepo, texts ...string) []*model.Message {
+	res := make([]*model.Message, 0, len(texts))
+	for _, text := range texts {
+		m, err := r.Save(context.Background(), &model.Message{Text: text, Status: model.CREATED, Version: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+func TestMemoryMessageRepo_SaveAndGet(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saved := saveMessages(t, r, "first", "second")
+
+	// when:
+	result, err := r.Get(context.Background(), saved[1].Id)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), saved[0].Id)
+	assert.Equal(t, int64(2), saved[1].Id)
+	assert.Equal(t, "second", result.Text)
+	assert.False(t, result.CreatedAt.IsZero())
+}
+
+func TestMemoryMessageRepo_Get_NotFound(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+
+	// when:
+	result, err := r.Get(context.Background(), 42)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, pg.ErrNoRows, err)
+}
+
+func TestMemoryMessageRepo_Update_VersionConflict(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saved := saveMessages(t, r, "first")[0]
+	stale := *saved
+
+	_, err := r.Update(context.Background(), saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	_, err = r.Update(context.Background(), &stale)
+
+	// then:
+	assert.Equal(t, model.ErrVersionConflict, err)
+	revisions, _ := r.ListRevisions(context.Background(), saved.Id)
+	assert.Len(t, revisions, 2)
+}
+
+func TestMemoryMessageRepo_UpdateAll_VersionConflict(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saved, err := r.SaveAll(context.Background(), []model.Message{{Text: "first", Version: 1}, {Text: "second", Version: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := saved[1]
+	if _, err := r.Update(context.Background(), &saved[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	_, err = r.UpdateAll(context.Background(), []model.Message{saved[0], stale})
+
+	// then:
+	assert.Equal(t, model.ErrVersionConflict, err)
+	first, _ := r.Get(context.Background(), saved[0].Id)
+	assert.Equal(t, int64(1), first.Version)
+}
+
+func TestMemoryMessageRepo_List_Pages(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saveMessages(t, r, "first", "second", "third")
+	deleted := saveMessages(t, r, "deleted")[0]
+	deleted.Status = model.DELETED
+	if _, err := r.Update(context.Background(), deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	firstPage, err := r.List(context.Background(), model.MessageFilter{}, nil, 2)
+	last := firstPage[len(firstPage)-1]
+	secondPage, _ := r.List(context.Background(), model.MessageFilter{},
+		&model.MessageCursor{CreatedAt: last.CreatedAt, Id: last.Id}, 2)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Len(t, firstPage, 2)
+	assert.Len(t, secondPage, 1)
+	assert.Equal(t, "first", secondPage[0].Text)
+}
+
+func TestMemoryMessageRepo_List_Owner(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saveMessages(t, r, "anonymous")
+	if _, err := r.Save(context.Background(), &model.Message{Text: "owned", Status: model.CREATED, Version: 1, OwnerId: "owner"}); err != nil {
+		t.Fatal(err)
+	}
+	owner := "owner"
+
+	// when:
+	result, err := r.List(context.Background(), model.MessageFilter{OwnerId: &owner}, nil, 10)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "owned", result[0].Text)
+}
+
+func TestMemoryMessageRepo_Search(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saveMessages(t, r, "Hello world", "hello there", "goodbye world")
+
+	// when:
+	result, err := r.Search(context.Background(), model.MessageFilter{Query: "world HELLO"}, 0, 10)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, "Hello world", result[0].Text)
+}
+
+func TestMemoryMessageRepo_RunInTx_Rollback(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saved := saveMessages(t, r, "first")[0]
+
+	// when:
+	err := r.RunInTx(context.Background(), func(tx MessageRepo) error {
+		m, _ := tx.GetForUpdate(context.Background(), saved.Id)
+		m.Text = "changed"
+		if _, err := tx.Update(context.Background(), m); err != nil {
+			return err
+		}
+		return assert.AnError
+	})
+
+	// then:
+	assert.Equal(t, assert.AnError, err)
+	result, _ := r.Get(context.Background(), saved.Id)
+	assert.Equal(t, "first", result.Text)
+	assert.Equal(t, saved.Version, result.Version)
+}
+
+func TestMemoryMessageRepo_RunInTx_RollbackKeepsConcurrentSave(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saved := saveMessages(t, r, "first")[0]
+	var concurrent *model.Message
+
+	// when:
+	err := r.RunInTx(context.Background(), func(tx MessageRepo) error {
+		if _, err := tx.Save(context.Background(), &model.Message{Text: "rolled back", Version: 1}); err != nil {
+			return err
+		}
+		concurrent = saveMessages(t, r, "concurrent")[0]
+		return assert.AnError
+	})
+	next := saveMessages(t, r, "next")[0]
+
+	// then:
+	assert.Equal(t, assert.AnError, err)
+	result, getErr := r.Get(context.Background(), concurrent.Id)
+	assert.Nil(t, getErr)
+	assert.Equal(t, "concurrent", result.Text)
+	_, getErr = r.Get(context.Background(), saved.Id+1)
+	assert.Equal(t, pg.ErrNoRows, getErr)
+	assert.Equal(t, concurrent.Id+1, next.Id)
+}
+
+func TestMemoryMessageRepo_PurgeDeleted(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	saved := saveMessages(t, r, "first", "second")
+	saved[0].Status = model.DELETED
+	saved[0].UpdatedAt = time.Now().Add(-time.Hour)
+	if _, err := r.Update(context.Background(), saved[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	n, err := r.PurgeDeleted(context.Background(), time.Now(), 10)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	_, err = r.Get(context.Background(), saved[0].Id)
+	assert.Equal(t, pg.ErrNoRows, err)
+}
+
+func TestMemoryMessageRepo_ConcurrentSave(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	var wg sync.WaitGroup
+
+	// when:
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Save(context.Background(), &model.Message{Text: "text", Status: model.CREATED, Version: 1})
+		}()
+	}
+	wg.Wait()
+
+	// then:
+	result, err := r.List(context.Background(), model.MessageFilter{}, nil, 100)
+	assert.Nil(t, err)
+	assert.Len(t, result, 50)
+}
+
+func TestMemoryMessageRepo_IdempotencyRecord_Expiry(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	now := time.Now()
+	expired := &model.IdempotencyRecord{OwnerId: "owner", Key: "key", RequestHash: "first", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)}
+	if err := r.SaveIdempotencyRecord(context.Background(), expired); err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	_, getErr := r.GetIdempotencyRecord(context.Background(), "owner", "key")
+	saveErr := r.SaveIdempotencyRecord(context.Background(),
+		&model.IdempotencyRecord{OwnerId: "owner", Key: "key", RequestHash: "second", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+	duplicateErr := r.SaveIdempotencyRecord(context.Background(),
+		&model.IdempotencyRecord{OwnerId: "owner", Key: "key", RequestHash: "third", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	// then:
+	assert.Equal(t, pg.ErrNoRows, getErr)
+	assert.Nil(t, saveErr)
+	assert.Equal(t, ErrIdempotencyKeyExists, duplicateErr)
+	record, err := r.GetIdempotencyRecord(context.Background(), "owner", "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "second", record.RequestHash)
+}
+
+func TestMemoryMessageRepo_IdempotencyRecord_ScopedToOwner(t *testing.T) {
+	// given:
+	r := NewMemoryMessageRepo()
+	now := time.Now()
+	first := &model.IdempotencyRecord{OwnerId: "first", Key: "key", RequestHash: "first", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	if err := r.SaveIdempotencyRecord(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+
+	// when:
+	_, getErr := r.GetIdempotencyRecord(context.Background(), "second", "key")
+	saveErr := r.SaveIdempotencyRecord(context.Background(),
+		&model.IdempotencyRecord{OwnerId: "second", Key: "key", RequestHash: "second", CreatedAt: now, ExpiresAt: now.Add(time.Hour)})
+
+	// then:
+	assert.Equal(t, pg.ErrNoRows, getErr)
+	assert.Nil(t, saveErr)
+	record, err := r.GetIdempotencyRecord(context.Background(), "first", "key")
+	assert.Nil(t, err)
+	assert.Equal(t, "first", record.RequestHash)
+}
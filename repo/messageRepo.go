@@ -1,32 +1,382 @@
 package repo
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	log "github.com/sirupsen/logrus"
+	"time"
 )
 
-// MessageRepo is an interface to operate with messages on Db level
+// textSearchConfig is the PostgreSQL text search configuration used for message text
+const textSearchConfig = "pg_catalog.simple"
+
+// maxTxAttempts bounds how many times RunInTx runs a transaction failing on serialization
+const maxTxAttempts = 3
+
+// ErrIdempotencyKeyExists is returned by SaveIdempotencyRecord when the key is taken by an unexpired record
+var ErrIdempotencyKeyExists = errors.New("pg: idempotency key exists")
+
+// MessageRepo is an interface to operate with messages on Db level. Queries are cancelled
+// together with ctx and bounded by the repo timeout, such failures wrap ctx.Err()
 type MessageRepo interface {
-	Save(m *model.Message) (*model.Message, error)
-	Update(m *model.Message) (*model.Message, error)
-	Get(id int64) (*model.Message, error)
+	Save(ctx context.Context, m *model.Message) (*model.Message, error)
+	Update(ctx context.Context, m *model.Message) (*model.Message, error)
+	Get(ctx context.Context, id int64) (*model.Message, error)
+	// GetForUpdate reads message locking its row until the end of the transaction, see RunInTx
+	GetForUpdate(ctx context.Context, id int64) (*model.Message, error)
+	// SaveAll, UpdateAll and GetAllForUpdate are batch counterparts of Save, Update and GetForUpdate.
+	// UpdateAll fails with model.ErrVersionConflict unless every message is unchanged, saving none of them.
+	SaveAll(ctx context.Context, ms []model.Message) ([]model.Message, error)
+	UpdateAll(ctx context.Context, ms []model.Message) ([]model.Message, error)
+	GetAllForUpdate(ctx context.Context, ids []int64) ([]model.Message, error)
+	List(ctx context.Context, filter model.MessageFilter, after *model.MessageCursor, limit int) ([]model.Message, error)
+	Search(ctx context.Context, filter model.MessageFilter, offset int, limit int) ([]model.Message, error)
+	PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error)
+	ListRevisions(ctx context.Context, messageId int64) ([]model.MessageRevision, error)
+	GetRevision(ctx context.Context, messageId int64, revision int64) (*model.MessageRevision, error)
+	// GetIdempotencyRecord returns record of the key used by the owner unless it is expired
+	GetIdempotencyRecord(ctx context.Context, ownerId string, key string) (*model.IdempotencyRecord, error)
+	SaveIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error
+	PurgeIdempotencyRecords(ctx context.Context, before time.Time, limit int) (int, error)
+	// RunInTx runs fn with a repo bound to a new transaction, committing it when fn succeeds
+	// and rolling it back otherwise. Transactions failing on serialization or deadlock are
+	// retried, so fn may run more than once.
+	RunInTx(ctx context.Context, fn func(MessageRepo) error) error
 }
 
-// MessageRepoImpl is an implementation of MessageRepo
+// MessageRepoImpl is an implementation of MessageRepo, it runs queries in tx when bound to one
 type MessageRepoImpl struct {
+	db *pg.DB
+	tx *pg.Tx
+	// timeout bounds every query or transaction, 0 disables it
+	timeout time.Duration
+}
+
+// NewMessageRepo returns MessageRepo operating on db
+func NewMessageRepo(db *pg.DB, timeout time.Duration) *MessageRepoImpl {
+	return &MessageRepoImpl{db: db, timeout: timeout}
+}
+
+func (r *MessageRepoImpl) RunInTx(ctx context.Context, fn func(MessageRepo) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = r.runInTransaction(ctx, func(tx *pg.Tx) error {
+			return fn(&MessageRepoImpl{db: r.db, tx: tx, timeout: r.timeout})
+		})
+		if !isSerializationFailure(err) || ctx.Err() != nil {
+			return err
+		}
+		if logger, ok := ctx.Value(model.ContextLogger).(*log.Entry); ok {
+			logger.Warnf("RunInTx : Transaction attempt %d failed on serialization, %v", attempt, err)
+		}
+	}
+	return err
+}
+
+// isSerializationFailure reports whether err is a PostgreSQL serialization failure or deadlock
+func isSerializationFailure(err error) bool {
+	var pgErr pg.Error
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	code := pgErr.Field('C')
+	return code == "40001" || code == "40P01"
+}
+
+// withTimeout bounds ctx by the repo timeout, zero timeout leaves ctx unbounded
+func (r *MessageRepoImpl) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.timeout)
+}
+
+// conn returns the transaction the repo is bound to, or db bound to ctx with timeout applied
+func (r *MessageRepoImpl) conn(ctx context.Context) (orm.DB, context.CancelFunc) {
+	if r.tx != nil {
+		return r.tx, func() {}
+	}
+	ctx, cancel := r.withTimeout(ctx)
+	return r.db.WithContext(ctx), cancel
+}
+
+// runInTransaction runs fn in a new transaction bound to ctx with timeout applied
+func (r *MessageRepoImpl) runInTransaction(ctx context.Context, fn func(*pg.Tx) error) error {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	err := r.db.WithContext(ctx).RunInTransaction(fn)
+	return dbError(ctx, err)
 }
 
-func (r *MessageRepoImpl) Save(m *model.Message) (*model.Message, error) {
-	_, err := Db.Model(m).Insert()
+// inTransaction runs fn in the transaction the repo is bound to, or in a new one
+func (r *MessageRepoImpl) inTransaction(ctx context.Context, fn func(orm.DB) error) error {
+	if r.tx != nil {
+		return dbError(r.tx.Context(), fn(r.tx))
+	}
+	return r.runInTransaction(ctx, func(tx *pg.Tx) error {
+		return fn(tx)
+	})
+}
+
+// dbError tells queries cancelled because ctx is done apart from other failures by wrapping ctx.Err()
+func dbError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%v: %w", err, ctx.Err())
+}
+
+// Save inserts message together with its first revision
+func (r *MessageRepoImpl) Save(ctx context.Context, m *model.Message) (*model.Message, error) {
+	err := r.inTransaction(ctx, func(tx orm.DB) error {
+		if _, err := tx.Model(m).Insert(); err != nil {
+			return err
+		}
+		_, err := tx.Model(model.NewMessageRevision(m)).Insert()
+		return err
+	})
 	return m, err
 }
 
-func (r *MessageRepoImpl) Update(m *model.Message) (*model.Message, error) {
-	_, err := Db.Model(m).WherePK().Update()
+// Update saves message only if its version is unchanged in Db, incrementing the version
+// and recording the new state as a revision in the same transaction
+func (r *MessageRepoImpl) Update(ctx context.Context, m *model.Message) (*model.Message, error) {
+	version := m.Version
+	m.Version++
+	err := r.inTransaction(ctx, func(tx orm.DB) error {
+		res, err := tx.Model(m).WherePK().Where("version = ?", version).Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return model.ErrVersionConflict
+		}
+		_, err = tx.Model(model.NewMessageRevision(m)).Insert()
+		return err
+	})
+	if err != nil {
+		m.Version = version
+	}
 	return m, err
 }
 
-func (r *MessageRepoImpl) Get(id int64) (*model.Message, error) {
+// SaveAll inserts messages with a single multi-row insert, together with their first revisions
+func (r *MessageRepoImpl) SaveAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	err := r.inTransaction(ctx, func(tx orm.DB) error {
+		if _, err := tx.Model(&ms).Insert(); err != nil {
+			return err
+		}
+		revisions := newRevisions(ms)
+		_, err := tx.Model(&revisions).Insert()
+		return err
+	})
+	return ms, err
+}
+
+// UpdateAll saves messages with a single multi-row update, incrementing their versions
+// and recording the new states as revisions in the same transaction
+func (r *MessageRepoImpl) UpdateAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	for i := range ms {
+		ms[i].Version++
+	}
+	err := r.inTransaction(ctx, func(tx orm.DB) error {
+		res, err := tx.Model(&ms).
+			Column("text", "status", "updated_at", "updated_by", "version").
+			Where("message.version = _data.version - 1").
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() != len(ms) {
+			return model.ErrVersionConflict
+		}
+		revisions := newRevisions(ms)
+		_, err = tx.Model(&revisions).Insert()
+		return err
+	})
+	if err != nil {
+		for i := range ms {
+			ms[i].Version--
+		}
+	}
+	return ms, err
+}
+
+func newRevisions(ms []model.Message) []model.MessageRevision {
+	res := make([]model.MessageRevision, 0, len(ms))
+	for i := range ms {
+		res = append(res, *model.NewMessageRevision(&ms[i]))
+	}
+	return res
+}
+
+func (r *MessageRepoImpl) Get(ctx context.Context, id int64) (*model.Message, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := model.Message{Id: id}
+	err := db.Model(&res).WherePK().Select()
+	return &res, dbError(db.Context(), err)
+}
+
+func (r *MessageRepoImpl) GetForUpdate(ctx context.Context, id int64) (*model.Message, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
 	res := model.Message{Id: id}
-	err := Db.Model(&res).WherePK().Select()
-	return &res, err
-}
\ No newline at end of file
+	err := db.Model(&res).WherePK().For("UPDATE").Select()
+	return &res, dbError(db.Context(), err)
+}
+
+// GetAllForUpdate reads existing messages with the given ids, locking their rows in id order
+func (r *MessageRepoImpl) GetAllForUpdate(ctx context.Context, ids []int64) ([]model.Message, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := make([]model.Message, 0, len(ids))
+	err := db.Model(&res).WhereIn("id IN (?)", ids).Order("id ASC").For("UPDATE").Select()
+	return res, dbError(db.Context(), err)
+}
+
+// List returns up to limit filtered messages ordered from newest to oldest, starting right after the given cursor
+func (r *MessageRepoImpl) List(ctx context.Context, filter model.MessageFilter, after *model.MessageCursor, limit int) ([]model.Message, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := make([]model.Message, 0)
+	q := applyFilter(db.Model(&res), filter).Order("created_at DESC", "id DESC").Limit(limit)
+	if after != nil {
+		q = q.Where("(created_at, id) < (?, ?)", after.CreatedAt, after.Id)
+	}
+	err := q.Select()
+	return res, dbError(db.Context(), err)
+}
+
+// Search returns filtered messages matching filter.Query, most relevant first
+func (r *MessageRepoImpl) Search(ctx context.Context, filter model.MessageFilter, offset int, limit int) ([]model.Message, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := make([]model.Message, 0)
+	err := applyFilter(db.Model(&res), filter).
+		Where("text_tsv @@ plainto_tsquery(?, ?)", textSearchConfig, filter.Query).
+		OrderExpr("ts_rank(text_tsv, plainto_tsquery(?, ?)) DESC", textSearchConfig, filter.Query).
+		Order("id DESC").
+		Offset(offset).
+		Limit(limit).
+		Select()
+	return res, dbError(db.Context(), err)
+}
+
+// PurgeDeleted permanently removes up to limit messages soft-deleted before the given time
+func (r *MessageRepoImpl) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res, err := db.Exec(`DELETE FROM message WHERE id IN (
+		SELECT id FROM message WHERE status = ? AND updated_at < ? ORDER BY id LIMIT ?)`,
+		model.DELETED, before, limit)
+	if err != nil {
+		return 0, dbError(db.Context(), err)
+	}
+	return res.RowsAffected(), nil
+}
+
+// ListRevisions returns all revisions of the message, oldest first
+func (r *MessageRepoImpl) ListRevisions(ctx context.Context, messageId int64) ([]model.MessageRevision, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := make([]model.MessageRevision, 0)
+	err := db.Model(&res).Where("message_id = ?", messageId).Order("revision ASC").Select()
+	return res, dbError(db.Context(), err)
+}
+
+func (r *MessageRepoImpl) GetRevision(ctx context.Context, messageId int64, revision int64) (*model.MessageRevision, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := model.MessageRevision{}
+	err := db.Model(&res).Where("message_id = ?", messageId).Where("revision = ?", revision).Select()
+	return &res, dbError(db.Context(), err)
+}
+
+func (r *MessageRepoImpl) GetIdempotencyRecord(ctx context.Context, ownerId string, key string) (*model.IdempotencyRecord, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res := model.IdempotencyRecord{OwnerId: ownerId, Key: key}
+	err := db.Model(&res).WherePK().Where("expires_at > ?", time.Now()).Select()
+	return &res, dbError(db.Context(), err)
+}
+
+// SaveIdempotencyRecord inserts record replacing an expired one with the same owner and key. Concurrent
+// inserts of a key wait for each other, so only one of them succeeds.
+func (r *MessageRepoImpl) SaveIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res, err := db.Model(record).
+		OnConflict("(owner_id, key) DO UPDATE").
+		Set("request_hash = EXCLUDED.request_hash").
+		Set("message_id = EXCLUDED.message_id").
+		Set("response = EXCLUDED.response").
+		Set("created_at = EXCLUDED.created_at").
+		Set("expires_at = EXCLUDED.expires_at").
+		Where("idempotency_key.expires_at <= EXCLUDED.created_at").
+		Insert()
+	if err != nil {
+		return dbError(db.Context(), err)
+	}
+	if res.RowsAffected() == 0 {
+		return ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+// PurgeIdempotencyRecords removes up to limit records expired before the given time
+func (r *MessageRepoImpl) PurgeIdempotencyRecords(ctx context.Context, before time.Time, limit int) (int, error) {
+	db, cancel := r.conn(ctx)
+	defer cancel()
+
+	res, err := db.Exec(`DELETE FROM idempotency_key WHERE (owner_id, key) IN (
+		SELECT owner_id, key FROM idempotency_key WHERE expires_at < ? ORDER BY expires_at LIMIT ?)`,
+		before, limit)
+	if err != nil {
+		return 0, dbError(db.Context(), err)
+	}
+	return res.RowsAffected(), nil
+}
+
+func applyFilter(q *orm.Query, filter model.MessageFilter) *orm.Query {
+	if !filter.IncludeDeleted {
+		q = q.Where("status <> ?", model.DELETED)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.CreatedFrom != nil {
+		q = q.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		q = q.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.UpdatedFrom != nil {
+		q = q.Where("updated_at >= ?", *filter.UpdatedFrom)
+	}
+	if filter.UpdatedTo != nil {
+		q = q.Where("updated_at < ?", *filter.UpdatedTo)
+	}
+	if filter.OwnerId != nil {
+		q = q.Where("owner_id = ?", *filter.OwnerId)
+	}
+	return q
+}
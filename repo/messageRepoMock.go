@@ -1,29 +1,114 @@
 package repo
 
 import (
+	"context"
 	"github.com/FatimaBabayeva/ms-go-example/model"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type MessageRepoMock struct {
 	mock.Mock
 }
 
-func (r *MessageRepoMock) Save(m *model.Message) (*model.Message, error) {
-	args := r.Called(m)
+func (r *MessageRepoMock) Save(ctx context.Context, m *model.Message) (*model.Message, error) {
+	args := r.Called(ctx, m)
 	return checkArguments(args)
 }
 
-func (r *MessageRepoMock) Update(m *model.Message) (*model.Message, error) {
-	args := r.Called(m)
+func (r *MessageRepoMock) Update(ctx context.Context, m *model.Message) (*model.Message, error) {
+	args := r.Called(ctx, m)
 	return checkArguments(args)
 }
 
-func (r *MessageRepoMock) Get(id int64) (*model.Message, error) {
-	args := r.Called(id)
+func (r *MessageRepoMock) Get(ctx context.Context, id int64) (*model.Message, error) {
+	args := r.Called(ctx, id)
 	return checkArguments(args)
 }
 
+func (r *MessageRepoMock) GetForUpdate(ctx context.Context, id int64) (*model.Message, error) {
+	args := r.Called(ctx, id)
+	return checkArguments(args)
+}
+
+func (r *MessageRepoMock) SaveAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	args := r.Called(ctx, ms)
+	return checkListArguments(args)
+}
+
+func (r *MessageRepoMock) UpdateAll(ctx context.Context, ms []model.Message) ([]model.Message, error) {
+	args := r.Called(ctx, ms)
+	return checkListArguments(args)
+}
+
+func (r *MessageRepoMock) GetAllForUpdate(ctx context.Context, ids []int64) ([]model.Message, error) {
+	args := r.Called(ctx, ids)
+	return checkListArguments(args)
+}
+
+// RunInTx runs fn against the mock itself, recording "Commit" when fn succeeds
+// and "Rollback" with the error of fn otherwise
+func (r *MessageRepoMock) RunInTx(ctx context.Context, fn func(MessageRepo) error) error {
+	r.Called(ctx)
+	if err := fn(r); err != nil {
+		r.MethodCalled("Rollback", err)
+		return err
+	}
+	return r.MethodCalled("Commit").Error(0)
+}
+
+func (r *MessageRepoMock) List(ctx context.Context, filter model.MessageFilter, after *model.MessageCursor, limit int) ([]model.Message, error) {
+	args := r.Called(ctx, filter, after, limit)
+	return checkListArguments(args)
+}
+
+func (r *MessageRepoMock) Search(ctx context.Context, filter model.MessageFilter, offset int, limit int) ([]model.Message, error) {
+	args := r.Called(ctx, filter, offset, limit)
+	return checkListArguments(args)
+}
+
+func (r *MessageRepoMock) PurgeDeleted(ctx context.Context, before time.Time, limit int) (int, error) {
+	args := r.Called(ctx, before, limit)
+	return args.Int(0), args.Error(1)
+}
+
+func (r *MessageRepoMock) ListRevisions(ctx context.Context, messageId int64) ([]model.MessageRevision, error) {
+	args := r.Called(ctx, messageId)
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.([]model.MessageRevision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MessageRepoMock) GetRevision(ctx context.Context, messageId int64, revision int64) (*model.MessageRevision, error) {
+	args := r.Called(ctx, messageId, revision)
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.(*model.MessageRevision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MessageRepoMock) GetIdempotencyRecord(ctx context.Context, ownerId string, key string) (*model.IdempotencyRecord, error) {
+	args := r.Called(ctx, ownerId, key)
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.(*model.IdempotencyRecord), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (r *MessageRepoMock) SaveIdempotencyRecord(ctx context.Context, record *model.IdempotencyRecord) error {
+	args := r.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (r *MessageRepoMock) PurgeIdempotencyRecords(ctx context.Context, before time.Time, limit int) (int, error) {
+	args := r.Called(ctx, before, limit)
+	return args.Int(0), args.Error(1)
+}
+
 func checkArguments(args mock.Arguments) (*model.Message, error) {
 	firstArg := args.Get(0)
 	if firstArg != nil {
@@ -31,3 +116,11 @@ func checkArguments(args mock.Arguments) (*model.Message, error) {
 	}
 	return nil, args.Error(1)
 }
+
+func checkListArguments(args mock.Arguments) ([]model.Message, error) {
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.([]model.Message), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
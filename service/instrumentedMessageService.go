@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/metrics"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"time"
+)
+
+// InstrumentedMessageService decorates MessageService with per-operation metrics and tracing spans
+type InstrumentedMessageService struct {
+	Next MessageService
+}
+
+func (s *InstrumentedMessageService) SaveMessage(ctx context.Context, message model.Message, idempotencyKey string) (result *model.Message, err error) {
+	ctx, end := instrument(ctx, "SaveMessage")
+	defer func() { end(err) }()
+	return s.Next.SaveMessage(ctx, message, idempotencyKey)
+}
+
+func (s *InstrumentedMessageService) GetMessageById(ctx context.Context, id int64, includeDeleted bool) (result *model.Message, err error) {
+	ctx, end := instrument(ctx, "GetMessageById")
+	defer func() { end(err) }()
+	return s.Next.GetMessageById(ctx, id, includeDeleted)
+}
+
+func (s *InstrumentedMessageService) UpdateMessageById(ctx context.Context, id int64, message model.Message, version int64) (result *model.Message, err error) {
+	ctx, end := instrument(ctx, "UpdateMessageById")
+	defer func() { end(err) }()
+	return s.Next.UpdateMessageById(ctx, id, message, version)
+}
+
+func (s *InstrumentedMessageService) DeleteMessageById(ctx context.Context, id int64, version int64) (err error) {
+	ctx, end := instrument(ctx, "DeleteMessageById")
+	defer func() { end(err) }()
+	return s.Next.DeleteMessageById(ctx, id, version)
+}
+
+func (s *InstrumentedMessageService) PatchMessageById(ctx context.Context, id int64, patch model.MessagePatch, version int64) (result *model.Message, err error) {
+	ctx, end := instrument(ctx, "PatchMessageById")
+	defer func() { end(err) }()
+	return s.Next.PatchMessageById(ctx, id, patch, version)
+}
+
+func (s *InstrumentedMessageService) RestoreMessageById(ctx context.Context, id int64) (result *model.Message, err error) {
+	ctx, end := instrument(ctx, "RestoreMessageById")
+	defer func() { end(err) }()
+	return s.Next.RestoreMessageById(ctx, id)
+}
+
+func (s *InstrumentedMessageService) ListMessages(ctx context.Context, filter model.MessageFilter, cursor string, limit int) (result *model.MessagePage, err error) {
+	ctx, end := instrument(ctx, "ListMessages")
+	defer func() { end(err) }()
+	return s.Next.ListMessages(ctx, filter, cursor, limit)
+}
+
+func (s *InstrumentedMessageService) SaveMessages(ctx context.Context, messages []model.Message, perItem bool) (result []model.BatchResult, err error) {
+	ctx, end := instrument(ctx, "SaveMessages")
+	defer func() { end(err) }()
+	return s.Next.SaveMessages(ctx, messages, perItem)
+}
+
+func (s *InstrumentedMessageService) UpdateMessages(ctx context.Context, messages []model.Message, perItem bool) (result []model.BatchResult, err error) {
+	ctx, end := instrument(ctx, "UpdateMessages")
+	defer func() { end(err) }()
+	return s.Next.UpdateMessages(ctx, messages, perItem)
+}
+
+func (s *InstrumentedMessageService) DeleteMessages(ctx context.Context, messages []model.Message, perItem bool) (result []model.BatchResult, err error) {
+	ctx, end := instrument(ctx, "DeleteMessages")
+	defer func() { end(err) }()
+	return s.Next.DeleteMessages(ctx, messages, perItem)
+}
+
+func (s *InstrumentedMessageService) ListMessageRevisions(ctx context.Context, id int64) (result []model.MessageRevision, err error) {
+	ctx, end := instrument(ctx, "ListMessageRevisions")
+	defer func() { end(err) }()
+	return s.Next.ListMessageRevisions(ctx, id)
+}
+
+func (s *InstrumentedMessageService) GetMessageRevision(ctx context.Context, id int64, revision int64) (result *model.MessageRevision, err error) {
+	ctx, end := instrument(ctx, "GetMessageRevision")
+	defer func() { end(err) }()
+	return s.Next.GetMessageRevision(ctx, id, revision)
+}
+
+func (s *InstrumentedMessageService) RevertMessageById(ctx context.Context, id int64, revision int64, version int64) (result *model.Message, err error) {
+	ctx, end := instrument(ctx, "RevertMessageById")
+	defer func() { end(err) }()
+	return s.Next.RevertMessageById(ctx, id, revision, version)
+}
+
+// instrument starts span of the operation, returned function ends it and records operation metrics
+func instrument(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, "MessageService."+operation)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			if msgErr, ok := err.(*ctmerror.MessageError); !ok || msgErr.HttpCode() >= 500 {
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+		span.End()
+		metrics.ObserveOperation(operation, start, err)
+	}
+}
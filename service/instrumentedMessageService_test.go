@@ -0,0 +1,21 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestInstrumentedMessageService_DelegatesCall(t *testing.T) {
+	// given:
+	next := MessageServiceMock{}
+	instrumented := InstrumentedMessageService{Next: &next}
+	next.On("DeleteMessageById", mock.Anything, id, int64(0)).Once().Return(notFoundErr)
+
+	// when:
+	err := instrumented.DeleteMessageById(mockContext(), id, 0)
+
+	// then:
+	assert.Equal(t, notFoundErr, err)
+	next.AssertExpectations(t)
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/go-pg/pg"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// MaxBatchSize bounds the number of items of a single batch operation
+const MaxBatchSize = 500
+
+var (
+	errInvalidBatchSize   = ctmerror.NewMessageErrorBuilder("error.go-example.invalid-batch-size", nil, http.StatusBadRequest)
+	errDuplicateBatchItem = ctmerror.NewMessageErrorBuilder("error.go-example.duplicate-batch-item", nil, http.StatusBadRequest)
+
+	// ErrBatchAborted is the error of items of an atomic batch which are not applied because other items failed
+	ErrBatchAborted = ctmerror.NewMessageErrorBuilder("error.go-example.batch-aborted", nil, http.StatusFailedDependency)
+
+	// errBatchItemFailed rolls back transaction of a batch some items of which failed
+	errBatchItemFailed = errors.New("batch item failed")
+)
+
+func (s *MessageServiceImpl) SaveMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.SaveMessages.start")
+
+	if len(messages) == 0 || len(messages) > MaxBatchSize {
+		logger.Errorf("ActionLog.SaveMessages.error : Batch of %d messages is rejected", len(messages))
+		return nil, errInvalidBatchSize
+	}
+
+	results := make([]model.BatchResult, len(messages))
+	if perItem {
+		for i, m := range messages {
+			results[i].Message, results[i].Err = s.SaveMessage(ctx, m, "")
+		}
+		logger.Info("ActionLog.SaveMessages.end")
+		return results, nil
+	}
+
+	batch := make([]model.Message, 0, len(messages))
+	for _, m := range messages {
+		batch = append(batch, model.Message{
+			Text:      m.Text,
+			Status:    model.CREATED,
+			Version:   1,
+			UpdatedBy: actor(ctx),
+			OwnerId:   subject(ctx),
+		})
+	}
+	saved, err := s.MsgRepo.SaveAll(ctx, batch)
+	if err != nil {
+		logger.Errorf("ActionLog.SaveMessages.error : Error saving %d messages %v,\n%s", len(batch), err, string(debug.Stack()))
+		return nil, ctmerror.NewMessageError(err)
+	}
+	for i := range saved {
+		results[i].Message = &saved[i]
+	}
+
+	logger.Info("ActionLog.SaveMessages.end")
+	return results, nil
+}
+
+func (s *MessageServiceImpl) UpdateMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.UpdateMessages.start")
+
+	if len(messages) == 0 || len(messages) > MaxBatchSize {
+		logger.Errorf("ActionLog.UpdateMessages.error : Batch of %d messages is rejected", len(messages))
+		return nil, errInvalidBatchSize
+	}
+
+	if perItem {
+		results := make([]model.BatchResult, len(messages))
+		for i, m := range messages {
+			results[i].Message, results[i].Err = s.UpdateMessageById(ctx, m.Id, model.Message{Text: m.Text}, m.Version)
+		}
+		logger.Info("ActionLog.UpdateMessages.end")
+		return results, nil
+	}
+
+	results, err := s.changeMessages(ctx, messages, func(stored *model.Message, m model.Message) {
+		stored.Text = m.Text
+	})
+	if err != nil {
+		logger.Errorf("ActionLog.UpdateMessages.error : Error updating %d messages %v,\n%s", len(messages), err, string(debug.Stack()))
+		return nil, err
+	}
+
+	logger.Info("ActionLog.UpdateMessages.end")
+	return results, nil
+}
+
+func (s *MessageServiceImpl) DeleteMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.DeleteMessages.start")
+
+	if len(messages) == 0 || len(messages) > MaxBatchSize {
+		logger.Errorf("ActionLog.DeleteMessages.error : Batch of %d messages is rejected", len(messages))
+		return nil, errInvalidBatchSize
+	}
+
+	if perItem {
+		results := make([]model.BatchResult, len(messages))
+		for i, m := range messages {
+			results[i].Err = s.DeleteMessageById(ctx, m.Id, m.Version)
+		}
+		logger.Info("ActionLog.DeleteMessages.end")
+		return results, nil
+	}
+
+	results, err := s.changeMessages(ctx, messages, func(stored *model.Message, m model.Message) {
+		stored.Status = model.DELETED
+	})
+	if err != nil {
+		logger.Errorf("ActionLog.DeleteMessages.error : Error deleting %d messages %v,\n%s", len(messages), err, string(debug.Stack()))
+		return nil, err
+	}
+
+	logger.Info("ActionLog.DeleteMessages.end")
+	return results, nil
+}
+
+// changeMessages applies change to the stored messages identified by messages in one transaction.
+// When any of them is missing, deleted, belongs to another user or has unexpected version nothing is saved: such items fail
+// on their own and the remaining ones with ErrBatchAborted.
+func (s *MessageServiceImpl) changeMessages(ctx context.Context, messages []model.Message,
+	change func(stored *model.Message, m model.Message)) ([]model.BatchResult, error) {
+
+	ids := make([]int64, 0, len(messages))
+	for _, m := range messages {
+		ids = append(ids, m.Id)
+	}
+
+	var results []model.BatchResult
+	err := s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		results = make([]model.BatchResult, len(messages))
+		stored, err := tx.GetAllForUpdate(ctx, ids)
+		if err != nil {
+			return ctmerror.NewMessageError(err)
+		}
+		storedById := make(map[int64]*model.Message, len(stored))
+		for i := range stored {
+			storedById[stored[i].Id] = &stored[i]
+		}
+
+		changed := make([]model.Message, 0, len(messages))
+		seen := make(map[int64]bool, len(messages))
+		failed := false
+		for i, m := range messages {
+			original, ok := storedById[m.Id]
+			switch {
+			case seen[m.Id]:
+				results[i].Err = errDuplicateBatchItem
+			case !ok || original.Status == model.DELETED:
+				results[i].Err = ctmerror.NewMessageError(pg.ErrNoRows)
+			case !canAccess(ctx, original):
+				results[i].Err = errAccessDenied
+			case m.Version != 0 && original.Version != m.Version:
+				results[i].Err = ctmerror.NewMessageError(model.ErrVersionConflict)
+			default:
+				change(original, m)
+				original.UpdatedAt = time.Now()
+				original.UpdatedBy = actor(ctx)
+				changed = append(changed, *original)
+			}
+			seen[m.Id] = true
+			failed = failed || results[i].Err != nil
+		}
+
+		if failed {
+			for i := range results {
+				if results[i].Err == nil {
+					results[i].Err = ErrBatchAborted
+				}
+			}
+			return errBatchItemFailed
+		}
+
+		updated, err := tx.UpdateAll(ctx, changed)
+		if err != nil {
+			return ctmerror.NewMessageError(err)
+		}
+		for i := range updated {
+			results[i].Message = &updated[i]
+		}
+		return nil
+	})
+	if err == errBatchItemFailed {
+		return results, nil
+	}
+	if err != nil {
+		return nil, asMessageError(err)
+	}
+	return results, nil
+}
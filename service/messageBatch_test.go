@@ -0,0 +1,123 @@
+package service
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+)
+
+func TestMessageServiceImpl_SaveMessages_Ok(t *testing.T) {
+	// given:
+	batch := []model.Message{
+		{Text: "FIRST", Status: model.CREATED, Version: 1, UpdatedBy: model.ActorAnonymous},
+		{Text: "SECOND", Status: model.CREATED, Version: 1, UpdatedBy: model.ActorAnonymous},
+	}
+	saved := []model.Message{batch[0], batch[1]}
+	saved[0].Id, saved[1].Id = 1, 2
+	mockRepo.On("SaveAll", mock.Anything, batch).Once().Return(saved, nil)
+
+	// when:
+	results, err := s.SaveMessages(mockContext(), []model.Message{{Text: "FIRST"}, {Id: 42, Text: "SECOND"}}, false)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, []model.BatchResult{{Message: &saved[0]}, {Message: &saved[1]}}, results)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_SaveMessages_PerItem(t *testing.T) {
+	// given:
+	mockRepo.On("Save", mock.Anything, mock.MatchedBy(func(m *model.Message) bool { return m.Text == "FIRST" })).
+		Once().Return(&model.Message{Id: 1, Text: "FIRST"}, nil)
+	mockRepo.On("Save", mock.Anything, mock.MatchedBy(func(m *model.Message) bool { return m.Text == "SECOND" })).
+		Once().Return(nil, assert.AnError)
+
+	// when:
+	results, err := s.SaveMessages(mockContext(), []model.Message{{Text: "FIRST"}, {Text: "SECOND"}}, true)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, []model.BatchResult{{Message: &model.Message{Id: 1, Text: "FIRST"}}, {Err: unexpectedErr}}, results)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_SaveMessages_InvalidSize(t *testing.T) {
+	// when:
+	results, err := s.SaveMessages(mockContext(), make([]model.Message, MaxBatchSize+1), false)
+
+	// then:
+	assert.Nil(t, results)
+	assert.Equal(t, errInvalidBatchSize, err)
+}
+
+func TestMessageServiceImpl_UpdateMessages_Ok(t *testing.T) {
+	// given:
+	stored := []model.Message{
+		{Id: 1, Text: "FIRST", Status: model.CREATED, Version: 1},
+		{Id: 2, Text: "SECOND", Status: model.CREATED, Version: 5},
+	}
+	expectCommit()
+	mockRepo.On("GetAllForUpdate", mock.Anything, []int64{2, 1}).Once().Return(stored, nil)
+	mockRepo.On("UpdateAll", mock.Anything, mock.MatchedBy(func(ms []model.Message) bool {
+		return len(ms) == 2 && ms[0].Id == 2 && ms[0].Text == "NEW SECOND" && ms[1].Id == 1 && ms[1].Text == "NEW FIRST"
+	})).Once().Return([]model.Message{{Id: 2, Version: 6}, {Id: 1, Version: 2}}, nil)
+
+	// when:
+	results, err := s.UpdateMessages(mockContext(), []model.Message{
+		{Id: 2, Text: "NEW SECOND", Version: 5},
+		{Id: 1, Text: "NEW FIRST"},
+	}, false)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, []model.BatchResult{{Message: &model.Message{Id: 2, Version: 6}}, {Message: &model.Message{Id: 1, Version: 2}}}, results)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_UpdateMessages_FailedItemAbortsBatch(t *testing.T) {
+	// given:
+	stored := []model.Message{
+		{Id: 1, Text: "FIRST", Status: model.CREATED, Version: 1},
+		{Id: 2, Text: "SECOND", Status: model.CREATED, Version: 5},
+		{Id: 3, Text: "THIRD", Status: model.DELETED, Version: 2},
+	}
+	expectRollback()
+	mockRepo.On("GetAllForUpdate", mock.Anything, []int64{1, 2, 3, 4, 1}).Once().Return(stored, nil)
+
+	// when:
+	results, err := s.UpdateMessages(mockContext(), []model.Message{
+		{Id: 1, Text: "NEW FIRST"},
+		{Id: 2, Text: "NEW SECOND", Version: 4},
+		{Id: 3, Text: "NEW THIRD"},
+		{Id: 4, Text: "NEW FOURTH"},
+		{Id: 1, Text: "NEWER FIRST"},
+	}, false)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, ErrBatchAborted, results[0].Err)
+	assert.Equal(t, "error.go-example.precondition-failed", results[1].Err.Error())
+	assert.Equal(t, notFoundErr.Error(), results[2].Err.Error())
+	assert.Equal(t, notFoundErr.Error(), results[3].Err.Error())
+	assert.Equal(t, errDuplicateBatchItem, results[4].Err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_DeleteMessages_Error(t *testing.T) {
+	// given:
+	stored := []model.Message{{Id: 1, Text: "FIRST", Status: model.CREATED, Version: 1}}
+	expectRollback()
+	mockRepo.On("GetAllForUpdate", mock.Anything, []int64{1}).Once().Return(stored, nil)
+	mockRepo.On("UpdateAll", mock.Anything, mock.MatchedBy(func(ms []model.Message) bool {
+		return len(ms) == 1 && ms[0].Status == model.DELETED
+	})).Once().Return(nil, model.ErrVersionConflict)
+
+	// when:
+	results, err := s.DeleteMessages(mockContext(), []model.Message{{Id: 1}}, false)
+
+	// then:
+	assert.Nil(t, results)
+	assert.Equal(t, "error.go-example.precondition-failed", err.Error())
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"github.com/FatimaBabayeva/ms-go-example/metrics"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// MessagePurger periodically removes messages that stayed soft-deleted longer than Retention, every Interval,
+// and expired idempotency keys, every IdempotencyInterval. Both are scheduled independently of each other.
+type MessagePurger struct {
+	MsgRepo             repo.MessageRepo
+	Retention           time.Duration
+	Interval            time.Duration
+	IdempotencyInterval time.Duration
+	BatchSize           int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// PurgesMessages tells whether soft-deleted messages are purged in background
+func (p *MessagePurger) PurgesMessages() bool {
+	return p.Retention > 0 && p.Interval > 0 && p.BatchSize > 0
+}
+
+// PurgesIdempotencyRecords tells whether expired idempotency keys are purged in background
+func (p *MessagePurger) PurgesIdempotencyRecords() bool {
+	return p.IdempotencyInterval > 0 && p.BatchSize > 0
+}
+
+// Start launches enabled kinds of purging in background, each on its own schedule
+func (p *MessagePurger) Start() {
+	if !p.PurgesMessages() && !p.PurgesIdempotencyRecords() {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.run()
+}
+
+// Stop signals background purging to finish and waits until the current batch is done
+func (p *MessagePurger) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
+
+func (p *MessagePurger) run() {
+	defer close(p.done)
+
+	// a nil channel never fires, so disabled kinds of purging are never run
+	var messages, keys <-chan time.Time
+	if p.PurgesMessages() {
+		ticker := time.NewTicker(p.Interval)
+		defer ticker.Stop()
+		messages = ticker.C
+	}
+	if p.PurgesIdempotencyRecords() {
+		ticker := time.NewTicker(p.IdempotencyInterval)
+		defer ticker.Stop()
+		keys = ticker.C
+	}
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-messages:
+			p.Purge()
+		case <-keys:
+			p.PurgeIdempotencyRecords()
+		}
+	}
+}
+
+// Purge runs a single purge pass, deleting in batches until no more expired messages are left
+func (p *MessagePurger) Purge() (int, error) {
+	logger := log.WithField(model.LoggerKeyOperation, "PurgeDeletedMessages")
+	logger.Info("ActionLog.PurgeDeletedMessages.start")
+	ctx := context.WithValue(context.Background(), model.ContextLogger, logger)
+
+	start := time.Now()
+	before := start.Add(-p.Retention)
+	total := 0
+	for {
+		n, err := p.MsgRepo.PurgeDeleted(ctx, before, p.BatchSize)
+		total += n
+		if err != nil {
+			logger.Errorf("ActionLog.PurgeDeletedMessages.error : Error purging messages deleted before %v, %v", before, err)
+			metrics.ObservePurge(total, err)
+			return total, err
+		}
+		if n < p.BatchSize || p.stopping() {
+			break
+		}
+	}
+
+	metrics.ObservePurge(total, nil)
+	logger.WithFields(log.Fields{
+		"purged":   total,
+		"duration": time.Since(start).String(),
+	}).Info("ActionLog.PurgeDeletedMessages.end")
+	return total, nil
+}
+
+// PurgeIdempotencyRecords removes expired idempotency keys in batches, they are no longer replayed anyway
+func (p *MessagePurger) PurgeIdempotencyRecords() (int, error) {
+	logger := log.WithField(model.LoggerKeyOperation, "PurgeIdempotencyRecords")
+	logger.Info("ActionLog.PurgeIdempotencyRecords.start")
+	ctx := context.WithValue(context.Background(), model.ContextLogger, logger)
+
+	start := time.Now()
+	before := start
+	total := 0
+	for {
+		n, err := p.MsgRepo.PurgeIdempotencyRecords(ctx, before, p.BatchSize)
+		total += n
+		if err != nil {
+			logger.Errorf("ActionLog.PurgeIdempotencyRecords.error : Error purging idempotency keys expired before %v, %v", before, err)
+			metrics.ObserveIdempotencyPurge(total, err)
+			return total, err
+		}
+		if n < p.BatchSize || p.stopping() {
+			break
+		}
+	}
+
+	metrics.ObserveIdempotencyPurge(total, nil)
+	logger.WithFields(log.Fields{
+		"purged":   total,
+		"duration": time.Since(start).String(),
+	}).Info("ActionLog.PurgeIdempotencyRecords.end")
+	return total, nil
+}
+
+func (p *MessagePurger) stopping() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
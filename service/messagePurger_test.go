@@ -0,0 +1,121 @@
+package service
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func TestMessagePurger_Purge_Ok(t *testing.T) {
+	// given:
+	purgerRepo := repo.MessageRepoMock{}
+	purger := MessagePurger{MsgRepo: &purgerRepo, Retention: time.Hour, BatchSize: 10}
+
+	start := time.Now()
+	beforeMatcher := mock.MatchedBy(func(before time.Time) bool {
+		return !before.Before(start.Add(-time.Hour)) && before.Before(start.Add(-time.Hour+time.Minute))
+	})
+	purgerRepo.On("PurgeDeleted", mock.Anything, beforeMatcher, 10).Twice().Return(10, nil)
+	purgerRepo.On("PurgeDeleted", mock.Anything, beforeMatcher, 10).Once().Return(3, nil)
+
+	// when:
+	n, err := purger.Purge()
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, 23, n)
+	purgerRepo.AssertExpectations(t)
+}
+
+func TestMessagePurger_Purge_Error(t *testing.T) {
+	// given:
+	purgerRepo := repo.MessageRepoMock{}
+	purger := MessagePurger{MsgRepo: &purgerRepo, Retention: time.Hour, BatchSize: 10}
+
+	purgerRepo.On("PurgeDeleted", mock.Anything, mock.Anything, 10).Once().Return(10, nil)
+	purgerRepo.On("PurgeDeleted", mock.Anything, mock.Anything, 10).Once().Return(0, assert.AnError)
+
+	// when:
+	n, err := purger.Purge()
+
+	// then:
+	assert.Equal(t, assert.AnError, err)
+	assert.Equal(t, 10, n)
+	purgerRepo.AssertExpectations(t)
+}
+
+func TestMessagePurger_StartStop(t *testing.T) {
+	// given:
+	purgerRepo := repo.MessageRepoMock{}
+	purger := MessagePurger{MsgRepo: &purgerRepo, Retention: time.Hour, Interval: time.Millisecond, BatchSize: 10}
+
+	purged := make(chan struct{}, 1)
+	purgerRepo.On("PurgeDeleted", mock.Anything, mock.Anything, 10).Return(0, nil).Run(func(mock.Arguments) {
+		select {
+		case purged <- struct{}{}:
+		default:
+		}
+	})
+
+	// when:
+	purger.Start()
+	<-purged
+	purger.Stop()
+
+	// then:
+	purgerRepo.AssertCalled(t, "PurgeDeleted", mock.Anything, mock.Anything, 10)
+}
+
+func TestMessagePurger_StartStop_IdempotencyRecordsWithoutRetention(t *testing.T) {
+	// given:
+	purgerRepo := repo.MessageRepoMock{}
+	purger := MessagePurger{MsgRepo: &purgerRepo, IdempotencyInterval: time.Millisecond, BatchSize: 10}
+
+	purged := make(chan struct{}, 1)
+	purgerRepo.On("PurgeIdempotencyRecords", mock.Anything, mock.Anything, 10).Return(0, nil).Run(func(mock.Arguments) {
+		select {
+		case purged <- struct{}{}:
+		default:
+		}
+	})
+
+	// when:
+	purger.Start()
+	<-purged
+	purger.Stop()
+
+	// then:
+	purgerRepo.AssertCalled(t, "PurgeIdempotencyRecords", mock.Anything, mock.Anything, 10)
+	purgerRepo.AssertNumberOfCalls(t, "PurgeDeleted", 0)
+}
+
+func TestMessagePurger_Start_Disabled(t *testing.T) {
+	// given:
+	purger := MessagePurger{MsgRepo: &repo.MessageRepoMock{}, Interval: time.Millisecond, BatchSize: 10}
+
+	// when:
+	purger.Start()
+	purger.Stop()
+
+	// then:
+	assert.Nil(t, purger.stop)
+}
+
+func TestMessagePurger_PurgeIdempotencyRecords_Ok(t *testing.T) {
+	// given:
+	purgerRepo := repo.MessageRepoMock{}
+	purger := MessagePurger{MsgRepo: &purgerRepo, BatchSize: 10}
+
+	purgerRepo.On("PurgeIdempotencyRecords", mock.Anything, mock.Anything, 10).Once().Return(10, nil)
+	purgerRepo.On("PurgeIdempotencyRecords", mock.Anything, mock.Anything, 10).Once().Return(4, nil)
+
+	// when:
+	n, err := purger.PurgeIdempotencyRecords()
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, 14, n)
+	purgerRepo.AssertExpectations(t)
+}
@@ -1,101 +1,611 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
 	"github.com/FatimaBabayeva/ms-go-example/model"
 	"github.com/FatimaBabayeva/ms-go-example/repo"
+	"github.com/FatimaBabayeva/ms-go-example/validation"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/go-pg/pg"
 	log "github.com/sirupsen/logrus"
+	"net/http"
 	"runtime/debug"
 	"time"
 )
 
 // MessageService is an interface to operate with messages
 type MessageService interface {
-	SaveMessage(ctx context.Context, message model.Message) (*model.Message, error)
-	GetMessageById(ctx context.Context, id int64) (*model.Message, error)
-	UpdateMessageById(ctx context.Context, id int64, message model.Message) (*model.Message, error)
-	DeleteMessageById(ctx context.Context, id int64) error
+	// SaveMessage saves message once per idempotency key, retries with the same key get the
+	// message saved first. Empty key disables the check.
+	SaveMessage(ctx context.Context, message model.Message, idempotencyKey string) (*model.Message, error)
+	GetMessageById(ctx context.Context, id int64, includeDeleted bool) (*model.Message, error)
+	// UpdateMessageById and DeleteMessageById fail with precondition error when version
+	// is not zero and differs from the current message version
+	UpdateMessageById(ctx context.Context, id int64, message model.Message, version int64) (*model.Message, error)
+	DeleteMessageById(ctx context.Context, id int64, version int64) error
+	PatchMessageById(ctx context.Context, id int64, patch model.MessagePatch, version int64) (*model.Message, error)
+	RestoreMessageById(ctx context.Context, id int64) (*model.Message, error)
+	ListMessageRevisions(ctx context.Context, id int64) ([]model.MessageRevision, error)
+	GetMessageRevision(ctx context.Context, id int64, revision int64) (*model.MessageRevision, error)
+	// RevertMessageById restores text of the message from the given revision, recording it as a new revision
+	RevertMessageById(ctx context.Context, id int64, revision int64, version int64) (*model.Message, error)
+	ListMessages(ctx context.Context, filter model.MessageFilter, cursor string, limit int) (*model.MessagePage, error)
+	// SaveMessages, UpdateMessages and DeleteMessages process a batch of at most MaxBatchSize messages
+	// in one transaction, so that a failing item aborts the others, or every item on its own when
+	// perItem is set. Messages to change are identified by Id and, unless it is zero, checked to have
+	// Version. Results follow the order of messages.
+	SaveMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error)
+	UpdateMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error)
+	DeleteMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error)
 }
 
+// Page size bounds for message listing
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var (
+	errForbidden            = ctmerror.NewMessageErrorBuilder("error.go-example.forbidden", nil, http.StatusForbidden)
+	errAccessDenied         = ctmerror.NewMessageErrorBuilder("error.go-example.access-denied", nil, http.StatusForbidden)
+	errMessageNotDeleted    = ctmerror.NewMessageErrorBuilder("error.go-example.message-not-deleted", nil, http.StatusConflict)
+	errUnsupportedPatch     = ctmerror.NewMessageErrorBuilder("error.go-example.unsupported-media-type", nil, http.StatusUnsupportedMediaType)
+	errRevisionNotFound     = ctmerror.NewMessageErrorBuilder("error.go-example.revision-not-found", nil, http.StatusNotFound)
+	errIdempotencyKeyReused = ctmerror.NewMessageErrorBuilder("error.go-example.idempotency-key-reused", nil, http.StatusConflict)
+)
+
 // MessageServiceImpl is an implementation of MessageService
 type MessageServiceImpl struct {
 	MsgRepo repo.MessageRepo
+	// IdempotencyTTL is how long responses to requests with idempotency key are replayed
+	IdempotencyTTL time.Duration
 }
 
-func (s *MessageServiceImpl) SaveMessage(ctx context.Context, message model.Message) (*model.Message, error) {
+func (s *MessageServiceImpl) SaveMessage(ctx context.Context, message model.Message, idempotencyKey string) (*model.Message, error) {
 	logger := ctx.Value(model.ContextLogger).(*log.Entry)
 	logger.Info("ActionLog.SaveMessage.start")
 
 	message.Id = 0
 	message.Status = model.CREATED
-	result, err := s.MsgRepo.Save(&message)
+	message.Version = 1
+	message.UpdatedBy = actor(ctx)
+	message.OwnerId = subject(ctx)
+
+	var result *model.Message
+	var err error
+	if idempotencyKey == "" {
+		result, err = s.MsgRepo.Save(ctx, &message)
+	} else {
+		result, err = s.saveMessageOnce(ctx, &message, idempotencyKey)
+	}
 	if err != nil {
 		logger.Errorf("ActionLog.SaveMessage.error : Error saving message %v,\n%s", err, string(debug.Stack()))
-		return nil, ctmerror.NewMessageError(err)
+		return nil, asMessageError(err)
 	}
 
 	logger.Info("ActionLog.SaveMessage.end")
 	return result, nil
 }
 
-func (s *MessageServiceImpl) GetMessageById(ctx context.Context, id int64) (*model.Message, error) {
+// saveMessageOnce saves message together with the idempotency key record, unless the owner of the message
+// already used the key. Then the message saved by the first request is returned when the requests match.
+func (s *MessageServiceImpl) saveMessageOnce(ctx context.Context, m *model.Message, key string) (*model.Message, error) {
+	hash := requestHash(m)
+	record, err := s.MsgRepo.GetIdempotencyRecord(ctx, m.OwnerId, key)
+	if err == nil {
+		return replay(record, hash)
+	}
+	if err != pg.ErrNoRows {
+		return nil, err
+	}
+
+	var result *model.Message
+	err = s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		saved, err := tx.Save(ctx, m)
+		if err != nil {
+			return err
+		}
+		response, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result = saved
+		return tx.SaveIdempotencyRecord(ctx, &model.IdempotencyRecord{
+			OwnerId:     m.OwnerId,
+			Key:         key,
+			RequestHash: hash,
+			MessageId:   saved.Id,
+			Response:    response,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.IdempotencyTTL),
+		})
+	})
+	if errors.Is(err, repo.ErrIdempotencyKeyExists) {
+		// a concurrent request with the same key was saved first, its transaction is committed by now
+		record, err = s.MsgRepo.GetIdempotencyRecord(ctx, m.OwnerId, key)
+		if err != nil {
+			return nil, err
+		}
+		return replay(record, hash)
+	}
+	return result, err
+}
+
+// replay returns the message saved by the request recorded under idempotency key,
+// provided that it matches the request being made
+func replay(record *model.IdempotencyRecord, hash string) (*model.Message, error) {
+	if record.RequestHash != hash {
+		return nil, errIdempotencyKeyReused
+	}
+
+	var m model.Message
+	if err := json.Unmarshal(record.Response, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// requestHash fingerprints the parts of a save request that determine the saved message
+func requestHash(m *model.Message) string {
+	sum := sha256.Sum256([]byte(m.UpdatedBy + "\n" + m.Text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *MessageServiceImpl) GetMessageById(ctx context.Context, id int64, includeDeleted bool) (*model.Message, error) {
 	logger := ctx.Value(model.ContextLogger).(*log.Entry)
 	logger.Info("ActionLog.GetMessageById.start")
 
-	result, err := s.MsgRepo.Get(id)
+	if includeDeleted && !isAdmin(ctx) {
+		logger.Warnf("ActionLog.GetMessageById.error : Deleted messages requested by non-admin, id = %d", id)
+		return nil, errForbidden
+	}
+
+	result, err := s.MsgRepo.Get(ctx, id)
 	if err != nil {
 		logger.Errorf("ActionLog.GetMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
 		return nil, ctmerror.NewMessageError(err)
 	}
+	if result.Status == model.DELETED && !includeDeleted {
+		logger.Errorf("ActionLog.GetMessageById.error : Message with id = %d is deleted", id)
+		return nil, ctmerror.NewMessageError(pg.ErrNoRows)
+	}
+	if !canAccess(ctx, result) {
+		logger.Warnf("ActionLog.GetMessageById.error : Message with id = %d belongs to another user", id)
+		return nil, errAccessDenied
+	}
 
 	logger.Info("ActionLog.GetMessageById.end")
 	return result, nil
 }
 
-func (s *MessageServiceImpl) UpdateMessageById(ctx context.Context, id int64, message model.Message) (*model.Message, error) {
+func (s *MessageServiceImpl) UpdateMessageById(ctx context.Context, id int64, message model.Message, version int64) (*model.Message, error) {
 	logger := ctx.Value(model.ContextLogger).(*log.Entry)
 	logger.Info("ActionLog.UpdateMessageById.start")
 
-	originalMsg, err := s.MsgRepo.Get(id)
+	var result *model.Message
+	err := s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		originalMsg, err := tx.GetForUpdate(ctx, id)
+		if err != nil {
+			logger.Errorf("ActionLog.UpdateMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		if originalMsg.Status == model.DELETED {
+			logger.Errorf("ActionLog.UpdateMessageById.error : Message with id = %d is deleted", id)
+			return ctmerror.NewMessageError(pg.ErrNoRows)
+		}
+		if !canAccess(ctx, originalMsg) {
+			logger.Warnf("ActionLog.UpdateMessageById.error : Message with id = %d belongs to another user", id)
+			return errAccessDenied
+		}
+		if version != 0 && originalMsg.Version != version {
+			logger.Errorf("ActionLog.UpdateMessageById.error : Message with id = %d has version %d, expected %d", id, originalMsg.Version, version)
+			return ctmerror.NewMessageError(model.ErrVersionConflict)
+		}
+
+		originalMsg.Text = message.Text
+		originalMsg.UpdatedAt = time.Now()
+		originalMsg.UpdatedBy = actor(ctx)
+
+		result, err = tx.Update(ctx, originalMsg)
+		if err != nil {
+			logger.Errorf("ActionLog.UpdateMessageById.error : Error updating message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, asMessageError(err)
+	}
+
+	logger.Info("ActionLog.UpdateMessageById.end")
+	return result, nil
+}
+
+func (s *MessageServiceImpl) DeleteMessageById(ctx context.Context, id int64, version int64) error {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.DeleteMessageById.start")
+
+	err := s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		originalMsg, err := tx.GetForUpdate(ctx, id)
+		if err != nil {
+			logger.Errorf("ActionLog.DeleteMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		if originalMsg.Status == model.DELETED {
+			logger.Errorf("ActionLog.DeleteMessageById.error : Message with id = %d is deleted", id)
+			return ctmerror.NewMessageError(pg.ErrNoRows)
+		}
+		if !canAccess(ctx, originalMsg) {
+			logger.Warnf("ActionLog.DeleteMessageById.error : Message with id = %d belongs to another user", id)
+			return errAccessDenied
+		}
+		if version != 0 && originalMsg.Version != version {
+			logger.Errorf("ActionLog.DeleteMessageById.error : Message with id = %d has version %d, expected %d", id, originalMsg.Version, version)
+			return ctmerror.NewMessageError(model.ErrVersionConflict)
+		}
+
+		originalMsg.UpdatedAt = time.Now()
+		originalMsg.UpdatedBy = actor(ctx)
+		originalMsg.Status = model.DELETED
+		_, err = tx.Update(ctx, originalMsg)
+		if err != nil {
+			logger.Errorf("ActionLog.DeleteMessageById.error : Error deleting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return asMessageError(err)
+	}
+
+	logger.Info("ActionLog.DeleteMessageById.end")
+	return nil
+}
+
+func (s *MessageServiceImpl) PatchMessageById(ctx context.Context, id int64, patch model.MessagePatch, version int64) (*model.Message, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.PatchMessageById.start")
+
+	if !patch.Type.IsSupported() {
+		logger.Errorf("ActionLog.PatchMessageById.error : Unsupported patch type %q", patch.Type)
+		return nil, errUnsupportedPatch
+	}
+
+	var result *model.Message
+	err := s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		originalMsg, err := tx.GetForUpdate(ctx, id)
+		if err != nil {
+			logger.Errorf("ActionLog.PatchMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		if originalMsg.Status == model.DELETED {
+			logger.Errorf("ActionLog.PatchMessageById.error : Message with id = %d is deleted", id)
+			return ctmerror.NewMessageError(pg.ErrNoRows)
+		}
+		if !canAccess(ctx, originalMsg) {
+			logger.Warnf("ActionLog.PatchMessageById.error : Message with id = %d belongs to another user", id)
+			return errAccessDenied
+		}
+		if version != 0 && originalMsg.Version != version {
+			logger.Errorf("ActionLog.PatchMessageById.error : Message with id = %d has version %d, expected %d", id, originalMsg.Version, version)
+			return ctmerror.NewMessageError(model.ErrVersionConflict)
+		}
+
+		patched, msgErr := applyPatch(originalMsg, patch)
+		if msgErr != nil {
+			logger.Errorf("ActionLog.PatchMessageById.error : Error applying %s to message with id = %d, %v", patch.Type, id, msgErr.BaseError())
+			return msgErr
+		}
+
+		originalMsg.Text = patched.Text
+		originalMsg.Status = patched.Status
+		originalMsg.UpdatedAt = time.Now()
+		originalMsg.UpdatedBy = actor(ctx)
+		result, err = tx.Update(ctx, originalMsg)
+		if err != nil {
+			logger.Errorf("ActionLog.PatchMessageById.error : Error updating message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, asMessageError(err)
+	}
+
+	logger.Info("ActionLog.PatchMessageById.end")
+	return result, nil
+}
+
+// applyPatch applies patch to the patchable fields of the message and validates the outcome
+func applyPatch(m *model.Message, patch model.MessagePatch) (*model.PatchableMessage, *ctmerror.MessageError) {
+	doc, err := json.Marshal(model.PatchableMessage{Text: m.Text, Status: m.Status})
 	if err != nil {
-		logger.Errorf("ActionLog.UpdateMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
 		return nil, ctmerror.NewMessageError(err)
 	}
 
-	if message.Text != "" {
-		originalMsg.Text = message.Text
+	switch patch.Type {
+	case model.MergePatch:
+		doc, err = jsonpatch.MergePatch(doc, patch.Document)
+	case model.JSONPatch:
+		var ops jsonpatch.Patch
+		ops, err = jsonpatch.DecodePatch(patch.Document)
+		if err == nil {
+			doc, err = ops.Apply(doc)
+		}
+	default:
+		return nil, errUnsupportedPatch
+	}
+	if err != nil {
+		return nil, ctmerror.NewMessageErrorBuilder("error.go-example.invalid-patch", err, http.StatusBadRequest)
+	}
+
+	var patched model.PatchableMessage
+	if msgErr := validation.Decode(bytes.NewReader(doc), &patched); msgErr != nil {
+		return nil, msgErr
+	}
+	return &patched, nil
+}
+
+func (s *MessageServiceImpl) RestoreMessageById(ctx context.Context, id int64) (*model.Message, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.RestoreMessageById.start")
+
+	var result *model.Message
+	err := s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		originalMsg, err := tx.GetForUpdate(ctx, id)
+		if err != nil {
+			logger.Errorf("ActionLog.RestoreMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		if !canAccess(ctx, originalMsg) {
+			logger.Warnf("ActionLog.RestoreMessageById.error : Message with id = %d belongs to another user", id)
+			return errAccessDenied
+		}
+		if originalMsg.Status != model.DELETED {
+			logger.Errorf("ActionLog.RestoreMessageById.error : Message with id = %d is not deleted", id)
+			return errMessageNotDeleted
+		}
+
 		originalMsg.UpdatedAt = time.Now()
+		originalMsg.UpdatedBy = actor(ctx)
+		originalMsg.Status = model.CREATED
+		result, err = tx.Update(ctx, originalMsg)
+		if err != nil {
+			logger.Errorf("ActionLog.RestoreMessageById.error : Error restoring message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, asMessageError(err)
+	}
+
+	logger.Info("ActionLog.RestoreMessageById.end")
+	return result, nil
+}
+
+func (s *MessageServiceImpl) ListMessageRevisions(ctx context.Context, id int64) ([]model.MessageRevision, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.ListMessageRevisions.start")
+
+	if _, err := s.getVisibleMessage(ctx, id); err != nil {
+		logger.Errorf("ActionLog.ListMessageRevisions.error : Error getting message with id = %d, %v", id, err)
+		return nil, err
 	}
 
-	result, err := s.MsgRepo.Update(originalMsg)
+	result, err := s.MsgRepo.ListRevisions(ctx, id)
 	if err != nil {
-		logger.Errorf("ActionLog.UpdateMessageById.error : Error updating message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+		logger.Errorf("ActionLog.ListMessageRevisions.error : Error listing revisions of message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
 		return nil, ctmerror.NewMessageError(err)
 	}
 
-	logger.Info("ActionLog.UpdateMessageById.end")
+	logger.Info("ActionLog.ListMessageRevisions.end")
 	return result, nil
 }
 
-func (s *MessageServiceImpl) DeleteMessageById(ctx context.Context, id int64) error {
+func (s *MessageServiceImpl) GetMessageRevision(ctx context.Context, id int64, revision int64) (*model.MessageRevision, error) {
 	logger := ctx.Value(model.ContextLogger).(*log.Entry)
-	logger.Info("ActionLog.DeleteMessageById.start")
+	logger.Info("ActionLog.GetMessageRevision.start")
+
+	if _, err := s.getVisibleMessage(ctx, id); err != nil {
+		logger.Errorf("ActionLog.GetMessageRevision.error : Error getting message with id = %d, %v", id, err)
+		return nil, err
+	}
 
-	originalMsg, err := s.MsgRepo.Get(id)
+	result, err := getRevision(ctx, s.MsgRepo, id, revision)
 	if err != nil {
-		logger.Errorf("ActionLog.DeleteMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
-		return ctmerror.NewMessageError(err)
+		logger.Errorf("ActionLog.GetMessageRevision.error : Error getting revision %d of message with id = %d, %v", revision, id, err)
+		return nil, err
 	}
 
-	originalMsg.UpdatedAt = time.Now()
-	originalMsg.Status = model.DELETED
-	_, err = s.MsgRepo.Update(originalMsg)
+	logger.Info("ActionLog.GetMessageRevision.end")
+	return result, nil
+}
+
+func (s *MessageServiceImpl) RevertMessageById(ctx context.Context, id int64, revision int64, version int64) (*model.Message, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.RevertMessageById.start")
+
+	var result *model.Message
+	err := s.MsgRepo.RunInTx(ctx, func(tx repo.MessageRepo) error {
+		originalMsg, err := tx.GetForUpdate(ctx, id)
+		if err != nil {
+			logger.Errorf("ActionLog.RevertMessageById.error : Error getting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		if originalMsg.Status == model.DELETED {
+			logger.Errorf("ActionLog.RevertMessageById.error : Message with id = %d is deleted", id)
+			return ctmerror.NewMessageError(pg.ErrNoRows)
+		}
+		if !canAccess(ctx, originalMsg) {
+			logger.Warnf("ActionLog.RevertMessageById.error : Message with id = %d belongs to another user", id)
+			return errAccessDenied
+		}
+		if version != 0 && originalMsg.Version != version {
+			logger.Errorf("ActionLog.RevertMessageById.error : Message with id = %d has version %d, expected %d", id, originalMsg.Version, version)
+			return ctmerror.NewMessageError(model.ErrVersionConflict)
+		}
+
+		rev, err := getRevision(ctx, tx, id, revision)
+		if err != nil {
+			logger.Errorf("ActionLog.RevertMessageById.error : Error getting revision %d of message with id = %d, %v", revision, id, err)
+			return err
+		}
+
+		originalMsg.Text = rev.Text
+		originalMsg.UpdatedAt = time.Now()
+		originalMsg.UpdatedBy = actor(ctx)
+		result, err = tx.Update(ctx, originalMsg)
+		if err != nil {
+			logger.Errorf("ActionLog.RevertMessageById.error : Error reverting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
+			return ctmerror.NewMessageError(err)
+		}
+		return nil
+	})
 	if err != nil {
-		logger.Errorf("ActionLog.DeleteMessageById.error : Error deleting message with id = %d, %v,\n%s", id, err, string(debug.Stack()))
-		return ctmerror.NewMessageError(err)
+		return nil, asMessageError(err)
 	}
 
-	logger.Info("ActionLog.DeleteMessageById.end")
-	return nil
+	logger.Info("ActionLog.RevertMessageById.end")
+	return result, nil
+}
+
+// getVisibleMessage returns the message unless it is deleted or belongs to another user,
+// admins see deleted messages and messages of every user
+func (s *MessageServiceImpl) getVisibleMessage(ctx context.Context, id int64) (*model.Message, error) {
+	m, err := s.MsgRepo.Get(ctx, id)
+	if err != nil {
+		return nil, ctmerror.NewMessageError(err)
+	}
+	if m.Status == model.DELETED && !isAdmin(ctx) {
+		return nil, ctmerror.NewMessageError(pg.ErrNoRows)
+	}
+	if !canAccess(ctx, m) {
+		return nil, errAccessDenied
+	}
+	return m, nil
+}
+
+func getRevision(ctx context.Context, r repo.MessageRepo, id int64, revision int64) (*model.MessageRevision, error) {
+	rev, err := r.GetRevision(ctx, id, revision)
+	if err == pg.ErrNoRows {
+		return nil, errRevisionNotFound
+	}
+	if err != nil {
+		return nil, ctmerror.NewMessageError(err)
+	}
+	return rev, nil
+}
+
+func (s *MessageServiceImpl) ListMessages(ctx context.Context, filter model.MessageFilter, cursor string, limit int) (*model.MessagePage, error) {
+	logger := ctx.Value(model.ContextLogger).(*log.Entry)
+	logger.Info("ActionLog.ListMessages.start")
+
+	if filter.IncludeDeleted && !isAdmin(ctx) {
+		logger.Warn("ActionLog.ListMessages.error : Deleted messages requested by non-admin")
+		return nil, errForbidden
+	}
+	if !isAdmin(ctx) {
+		owner := subject(ctx)
+		filter.OwnerId = &owner
+	}
+
+	var after *model.MessageCursor
+	if cursor != "" {
+		c, err := model.DecodeMessageCursor(cursor)
+		if err == nil {
+			err = c.Validate(filter.Query != "")
+		}
+		if err != nil {
+			logger.Errorf("ActionLog.ListMessages.error : Invalid cursor %q, %v", cursor, err)
+			return nil, ctmerror.NewMessageErrorBuilder("error.go-example.invalid-cursor", err, http.StatusBadRequest)
+		}
+		after = c
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	} else if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	// one extra row tells whether there is a next page
+	var messages []model.Message
+	var err error
+	offset := 0
+	if filter.Query != "" {
+		if after != nil {
+			offset = after.Offset
+		}
+		messages, err = s.MsgRepo.Search(ctx, filter, offset, limit+1)
+	} else {
+		messages, err = s.MsgRepo.List(ctx, filter, after, limit+1)
+	}
+	if err != nil {
+		logger.Errorf("ActionLog.ListMessages.error : Error listing messages %v,\n%s", err, string(debug.Stack()))
+		return nil, ctmerror.NewMessageError(err)
+	}
+
+	page := model.MessagePage{Items: messages}
+	if len(messages) > limit {
+		page.Items = messages[:limit]
+		if filter.Query != "" {
+			page.Next = model.MessageCursor{Offset: offset + limit}.Encode()
+		} else {
+			last := page.Items[limit-1]
+			page.Next = model.MessageCursor{CreatedAt: last.CreatedAt, Id: last.Id}.Encode()
+		}
+	}
+
+	logger.Info("ActionLog.ListMessages.end")
+	return &page, nil
+}
+
+// asMessageError passes message errors returned from a transaction through, wrapping
+// failures of the transaction itself
+func asMessageError(err error) *ctmerror.MessageError {
+	var msgErr *ctmerror.MessageError
+	if errors.As(err, &msgErr) {
+		return msgErr
+	}
+	return ctmerror.NewMessageError(err)
+}
+
+// actor identifies who performs the change, it is recorded in message history.
+// Subject of the bearer token is preferred over the admin key
+func actor(ctx context.Context) string {
+	if subject := subject(ctx); subject != "" {
+		return subject
+	}
+	if isAdmin(ctx) {
+		return model.ActorAdmin
+	}
+	return model.ActorAnonymous
+}
+
+// subject returns subject of the bearer token the request was authenticated with,
+// it is empty when authentication is disabled
+func subject(ctx context.Context) string {
+	subject, _ := ctx.Value(model.ContextSubject).(string)
+	return subject
+}
+
+// canAccess reports whether the request may read or change the message, which is allowed
+// to its owner and admins only
+func canAccess(ctx context.Context, m *model.Message) bool {
+	return isAdmin(ctx) || m.OwnerId == subject(ctx)
+}
+
+// isAdmin reports whether the request was authorized as admin by the middleware
+func isAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(model.ContextAdmin).(bool)
+	return admin
 }
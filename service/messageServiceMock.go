@@ -10,27 +10,92 @@ type MessageServiceMock struct {
 	mock.Mock
 }
 
-func (s *MessageServiceMock) SaveMessage(ctx context.Context, message model.Message) (*model.Message, error) {
+func (s *MessageServiceMock) SaveMessage(ctx context.Context, message model.Message, idempotencyKey string) (*model.Message, error) {
 	// args hold the arguments that should be returned when this method is called.
-	args := s.Called(ctx, message)
+	args := s.Called(ctx, message, idempotencyKey)
 	return checkArguments(args)
 }
 
-func (s *MessageServiceMock) GetMessageById(ctx context.Context, id int64) (*model.Message, error) {
-	args := s.Called(ctx, id)
+func (s *MessageServiceMock) GetMessageById(ctx context.Context, id int64, includeDeleted bool) (*model.Message, error) {
+	args := s.Called(ctx, id, includeDeleted)
 	return checkArguments(args)
 }
 
-func (s *MessageServiceMock) UpdateMessageById(ctx context.Context, id int64, message model.Message) (*model.Message, error) {
-	args := s.Called(ctx, id, message)
+func (s *MessageServiceMock) UpdateMessageById(ctx context.Context, id int64, message model.Message, version int64) (*model.Message, error) {
+	args := s.Called(ctx, id, message, version)
 	return checkArguments(args)
 }
 
-func (s *MessageServiceMock) DeleteMessageById(ctx context.Context, id int64) error {
-	args := s.Called(ctx, id)
+func (s *MessageServiceMock) DeleteMessageById(ctx context.Context, id int64, version int64) error {
+	args := s.Called(ctx, id, version)
 	return args.Error(0)
 }
 
+func (s *MessageServiceMock) PatchMessageById(ctx context.Context, id int64, patch model.MessagePatch, version int64) (*model.Message, error) {
+	args := s.Called(ctx, id, patch, version)
+	return checkArguments(args)
+}
+
+func (s *MessageServiceMock) RestoreMessageById(ctx context.Context, id int64) (*model.Message, error) {
+	args := s.Called(ctx, id)
+	return checkArguments(args)
+}
+
+func (s *MessageServiceMock) ListMessages(ctx context.Context, filter model.MessageFilter, cursor string, limit int) (*model.MessagePage, error) {
+	args := s.Called(ctx, filter, cursor, limit)
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.(*model.MessagePage), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (s *MessageServiceMock) ListMessageRevisions(ctx context.Context, id int64) ([]model.MessageRevision, error) {
+	args := s.Called(ctx, id)
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.([]model.MessageRevision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (s *MessageServiceMock) GetMessageRevision(ctx context.Context, id int64, revision int64) (*model.MessageRevision, error) {
+	args := s.Called(ctx, id, revision)
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.(*model.MessageRevision), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (s *MessageServiceMock) RevertMessageById(ctx context.Context, id int64, revision int64, version int64) (*model.Message, error) {
+	args := s.Called(ctx, id, revision, version)
+	return checkArguments(args)
+}
+
+func (s *MessageServiceMock) SaveMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error) {
+	args := s.Called(ctx, messages, perItem)
+	return checkBatchArguments(args)
+}
+
+func (s *MessageServiceMock) UpdateMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error) {
+	args := s.Called(ctx, messages, perItem)
+	return checkBatchArguments(args)
+}
+
+func (s *MessageServiceMock) DeleteMessages(ctx context.Context, messages []model.Message, perItem bool) ([]model.BatchResult, error) {
+	args := s.Called(ctx, messages, perItem)
+	return checkBatchArguments(args)
+}
+
+func checkBatchArguments(args mock.Arguments) ([]model.BatchResult, error) {
+	firstArg := args.Get(0)
+	if firstArg != nil {
+		return firstArg.([]model.BatchResult), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func checkArguments(args mock.Arguments) (*model.Message, error) {
 	firstArg := args.Get(0)
 	if firstArg != nil {
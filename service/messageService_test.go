@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
 	"github.com/FatimaBabayeva/ms-go-example/model"
 	"github.com/FatimaBabayeva/ms-go-example/repo"
@@ -10,11 +12,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 var (
 	mockRepo      = repo.MessageRepoMock{}
-	s             = MessageServiceImpl{MsgRepo: &mockRepo}
+	s             = MessageServiceImpl{MsgRepo: &mockRepo, IdempotencyTTL: time.Hour}
 	unexpectedErr = ctmerror.NewMessageErrorBuilder("error.go-example.unexpected-error", assert.AnError, 500)
 	notFoundErr   = ctmerror.NewMessageErrorBuilder("error.go-example.message-not-found", pg.ErrNoRows, 404)
 
@@ -35,6 +38,39 @@ func mockContext() context.Context {
 	return ctx
 }
 
+func mockAdminContext() context.Context {
+	return context.WithValue(mockContext(), model.ContextAdmin, true)
+}
+
+func mockUserContext(subject string) context.Context {
+	return context.WithValue(mockContext(), model.ContextSubject, subject)
+}
+
+// ownedBy restricts filter to messages of the owner, as ListMessages does for non-admins
+func ownedBy(owner string, filter model.MessageFilter) model.MessageFilter {
+	filter.OwnerId = &owner
+	return filter
+}
+
+// expectCommit and expectRollback set up a transaction expected to end with commit or rollback
+func expectCommit() {
+	mockRepo.On("RunInTx", mock.Anything).Once()
+	mockRepo.On("Commit").Once().Return(nil)
+}
+
+func expectRollback() {
+	mockRepo.On("RunInTx", mock.Anything).Once()
+	mockRepo.On("Rollback", mock.Anything).Once()
+}
+
+func deletedMessage() *model.Message {
+	return &model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "DELETED",
+	}
+}
+
 func TestMessageServiceImpl_SaveMessage_Ok(t *testing.T) {
 	// given:
 	message := model.Message{
@@ -42,10 +78,13 @@ func TestMessageServiceImpl_SaveMessage_Ok(t *testing.T) {
 		Text:   "MOCK_TEXT",
 		Status: "CREATED",
 	}
-	mockRepo.On("Save", &message).Once().Return(&message, nil)
+	savedMessage := message
+	savedMessage.Version = 1
+	savedMessage.UpdatedBy = model.ActorAnonymous
+	mockRepo.On("Save", mock.Anything, &savedMessage).Once().Return(&savedMessage, nil)
 
 	// when:
-	result, err := s.SaveMessage(mockContext(), message)
+	result, err := s.SaveMessage(mockContext(), message, "")
 
 	// then:
 	assert.Nil(t, err)
@@ -58,10 +97,10 @@ func TestMessageServiceImpl_SaveMessage_Ok(t *testing.T) {
 func TestMessageServiceImpl_GetMessageById_Ok(t *testing.T) {
 	// given:
 	message := model.Message{Id: id}
-	mockRepo.On("Get", id).Once().Return(&message, nil)
+	mockRepo.On("Get", mock.Anything, id).Once().Return(&message, nil)
 
 	// when:
-	result, err := s.GetMessageById(mockContext(), id)
+	result, err := s.GetMessageById(mockContext(), id, false)
 
 	// then:
 	assert.Nil(t, err)
@@ -84,15 +123,16 @@ func TestMessageServiceImpl_UpdateMessageById_Ok(t *testing.T) {
 		Status: "CREATED",
 	}
 
-	mockRepo.On("Get", id).Once().Return(&originalMessage, nil)
-	mockRepo.On("Update", mock.MatchedBy(func(msg *model.Message) bool {
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
 		return msg.Id == id &&
 			msg.Text == message.Text &&
 			msg.Status == originalMessage.Status
 	})).Once().Return(&updatedMessage, nil)
+	expectCommit()
 
 	// when:
-	result, err := s.UpdateMessageById(mockContext(), id, message)
+	result, err := s.UpdateMessageById(mockContext(), id, message, 0)
 
 	// then:
 	assert.Nil(t, err)
@@ -114,15 +154,16 @@ func TestMessageServiceImpl_DeleteMessageById_Ok(t *testing.T) {
 		Status: "DELETED",
 	}
 
-	mockRepo.On("Get", id).Once().Return(&originalMessage, nil)
-	mockRepo.On("Update", mock.MatchedBy(func(msg *model.Message) bool {
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
 		return msg.Id == id &&
 			msg.Text == originalMessage.Text &&
 			msg.Status == deletedMessage.Status
 	})).Once().Return(&deletedMessage, nil)
+	expectCommit()
 
 	// when:
-	err := s.DeleteMessageById(mockContext(), id)
+	err := s.DeleteMessageById(mockContext(), id, 0)
 
 	// then:
 	assert.Nil(t, err)
@@ -132,10 +173,10 @@ func TestMessageServiceImpl_DeleteMessageById_Ok(t *testing.T) {
 func TestMessageServiceImpl_SaveMessage_Error(t *testing.T) {
 	// given:
 	message := model.Message{}
-	mockRepo.On("Save", mock.Anything).Once().Return(nil, assert.AnError)
+	mockRepo.On("Save", mock.Anything, mock.Anything).Once().Return(nil, assert.AnError)
 
 	// when:
-	result, err := s.SaveMessage(mockContext(), message)
+	result, err := s.SaveMessage(mockContext(), message, "")
 
 	// then:
 	assert.NotNil(t, err)
@@ -144,13 +185,176 @@ func TestMessageServiceImpl_SaveMessage_Error(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestMessageServiceImpl_SaveMessage_IdempotencyKey(t *testing.T) {
+	// given:
+	message := model.Message{Text: "MOCK_TEXT"}
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1, UpdatedBy: model.ActorAnonymous}
+	mockRepo.On("GetIdempotencyRecord", mock.Anything, "", "MOCK_KEY").Once().Return(nil, pg.ErrNoRows)
+	expectCommit()
+	mockRepo.On("Save", mock.Anything, mock.Anything).Once().Return(&savedMessage, nil)
+	mockRepo.On("SaveIdempotencyRecord", mock.Anything, mock.MatchedBy(func(r *model.IdempotencyRecord) bool {
+		return r.Key == "MOCK_KEY" && r.MessageId == id && r.RequestHash != "" && r.ExpiresAt.Equal(r.CreatedAt.Add(time.Hour))
+	})).Once().Return(nil)
+
+	// when:
+	result, err := s.SaveMessage(mockContext(), message, "MOCK_KEY")
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, &savedMessage, result)
+	mockRepo.AssertExpectations(t)
+}
+
+// idempotencyRecord returns record of the request saving message, as SaveMessage stores it
+func idempotencyRecord(t *testing.T, message model.Message) *model.IdempotencyRecord {
+	response, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &model.IdempotencyRecord{
+		Key:         "MOCK_KEY",
+		RequestHash: requestHash(&message),
+		MessageId:   message.Id,
+		Response:    response,
+	}
+}
+
+func TestMessageServiceImpl_SaveMessage_Replay(t *testing.T) {
+	// given:
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1, UpdatedBy: model.ActorAnonymous,
+		CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	mockRepo.On("GetIdempotencyRecord", mock.Anything, "", "MOCK_KEY").Once().Return(idempotencyRecord(t, savedMessage), nil)
+
+	// when:
+	result, err := s.SaveMessage(mockContext(), model.Message{Text: "MOCK_TEXT"}, "MOCK_KEY")
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, &savedMessage, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_SaveMessage_IdempotencyKeyReused(t *testing.T) {
+	// given:
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", UpdatedBy: model.ActorAnonymous}
+	mockRepo.On("GetIdempotencyRecord", mock.Anything, "", "MOCK_KEY").Once().Return(idempotencyRecord(t, savedMessage), nil)
+
+	// when:
+	result, err := s.SaveMessage(mockContext(), model.Message{Text: "OTHER_TEXT"}, "MOCK_KEY")
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, errIdempotencyKeyReused, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_SaveMessage_ConcurrentIdempotencyKey(t *testing.T) {
+	// given:
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1, UpdatedBy: model.ActorAnonymous}
+	mockRepo.On("GetIdempotencyRecord", mock.Anything, "", "MOCK_KEY").Once().Return(nil, pg.ErrNoRows)
+	expectRollback()
+	mockRepo.On("Save", mock.Anything, mock.Anything).Once().Return(&model.Message{Id: id + 1}, nil)
+	mockRepo.On("SaveIdempotencyRecord", mock.Anything, mock.Anything).Once().Return(repo.ErrIdempotencyKeyExists)
+	mockRepo.On("GetIdempotencyRecord", mock.Anything, "", "MOCK_KEY").Once().Return(idempotencyRecord(t, savedMessage), nil)
+
+	// when:
+	result, err := s.SaveMessage(mockContext(), model.Message{Text: "MOCK_TEXT"}, "MOCK_KEY")
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, &savedMessage, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_SaveMessage_IdempotencyKeyOfOwner(t *testing.T) {
+	// given:
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1, OwnerId: "MOCK_SUBJECT"}
+	mockRepo.On("GetIdempotencyRecord", mock.Anything, "MOCK_SUBJECT", "MOCK_KEY").Once().Return(nil, pg.ErrNoRows)
+	expectCommit()
+	mockRepo.On("Save", mock.Anything, mock.Anything).Once().Return(&savedMessage, nil)
+	mockRepo.On("SaveIdempotencyRecord", mock.Anything, mock.MatchedBy(func(r *model.IdempotencyRecord) bool {
+		return r.OwnerId == "MOCK_SUBJECT" && r.Key == "MOCK_KEY"
+	})).Once().Return(nil)
+
+	// when:
+	result, err := s.SaveMessage(mockUserContext("MOCK_SUBJECT"), model.Message{Text: "MOCK_TEXT"}, "MOCK_KEY")
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, &savedMessage, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_SaveMessage_RecordsOwner(t *testing.T) {
+	// given:
+	savedMessage := model.Message{Id: id, Text: "MOCK_TEXT", OwnerId: "MOCK_SUBJECT"}
+	mockRepo.On("Save", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.OwnerId == "MOCK_SUBJECT" && msg.UpdatedBy == "MOCK_SUBJECT"
+	})).Once().Return(&savedMessage, nil)
+
+	// when:
+	result, err := s.SaveMessage(mockUserContext("MOCK_SUBJECT"), model.Message{Text: "MOCK_TEXT"}, "")
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, &savedMessage, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_GetMessageById_AccessDenied(t *testing.T) {
+	// given:
+	message := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, OwnerId: "OWNER"}
+	mockRepo.On("Get", mock.Anything, id).Times(3).Return(&message, nil)
+
+	// when:
+	_, otherErr := s.GetMessageById(mockUserContext("OTHER"), id, false)
+	_, ownerErr := s.GetMessageById(mockUserContext("OWNER"), id, false)
+	_, adminErr := s.GetMessageById(mockAdminContext(), id, false)
+
+	// then:
+	assert.Equal(t, errAccessDenied, otherErr)
+	assert.Equal(t, 403, otherErr.(*ctmerror.MessageError).HttpCode())
+	assert.Nil(t, ownerErr)
+	assert.Nil(t, adminErr)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_UpdateMessageById_AccessDenied(t *testing.T) {
+	// given:
+	originalMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1, OwnerId: "OWNER"}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	result, err := s.UpdateMessageById(mockUserContext("OTHER"), id, model.Message{Text: "UPDATED_TEXT"}, 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, errAccessDenied, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_DeleteMessageById_AccessDenied(t *testing.T) {
+	// given:
+	originalMessage := model.Message{Id: id, Text: "MOCK_TEXT", Status: model.CREATED, Version: 1}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	err := s.DeleteMessageById(mockUserContext("OTHER"), id, 0)
+
+	// then:
+	assert.Equal(t, errAccessDenied, err)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestMessageServiceImpl_GetMessageById_Error(t *testing.T) {
 	for _, errCase := range errorTable {
 		// given:
-		mockRepo.On("Get", id).Once().Return(nil, errCase.repoError)
+		mockRepo.On("Get", mock.Anything, id).Once().Return(nil, errCase.repoError)
 
 		// when:
-		result, err := s.GetMessageById(mockContext(), id)
+		result, err := s.GetMessageById(mockContext(), id, false)
 
 		// then:
 		assert.Nil(t, result)
@@ -163,10 +367,11 @@ func TestMessageServiceImpl_GetMessageById_Error(t *testing.T) {
 func TestMessageServiceImpl_UpdateMessageById_MessageNotFound(t *testing.T) {
 	// given:
 	message := model.Message{Text: "UPDATED_TEXT"}
-	mockRepo.On("Get", id).Once().Return(nil, pg.ErrNoRows)
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(nil, pg.ErrNoRows)
+	expectRollback()
 
 	// when:
-	result, err := s.UpdateMessageById(mockContext(), id, message)
+	result, err := s.UpdateMessageById(mockContext(), id, message, 0)
 
 	// then:
 	assert.Nil(t, result)
@@ -184,11 +389,12 @@ func TestMessageServiceImpl_UpdateMessageById_AnyError(t *testing.T) {
 		Text:   "MOCK_TEXT",
 		Status: "CREATED",
 	}
-	mockRepo.On("Get", id).Once().Return(&originalMessage, nil)
-	mockRepo.On("Update", mock.Anything).Once().Return(nil, assert.AnError)
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.Anything).Once().Return(nil, assert.AnError)
+	expectRollback()
 
 	// when:
-	result, err := s.UpdateMessageById(mockContext(), id, message)
+	result, err := s.UpdateMessageById(mockContext(), id, message, 0)
 
 	// then:
 	assert.Nil(t, result)
@@ -199,10 +405,11 @@ func TestMessageServiceImpl_UpdateMessageById_AnyError(t *testing.T) {
 
 func TestMessageServiceImpl_DeleteMessageById_MessageNotFound(t *testing.T) {
 	// given:
-	mockRepo.On("Get", id).Once().Return(nil, pg.ErrNoRows)
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(nil, pg.ErrNoRows)
+	expectRollback()
 
 	// when:
-	err := s.DeleteMessageById(mockContext(), id)
+	err := s.DeleteMessageById(mockContext(), id, 0)
 
 	// then:
 	assert.NotNil(t, err)
@@ -217,14 +424,603 @@ func TestMessageServiceImpl_DeleteMessageById_AnyError(t *testing.T) {
 		Text:   "MOCK_TEXT",
 		Status: "CREATED",
 	}
-	mockRepo.On("Get", id).Once().Return(&originalMessage, nil)
-	mockRepo.On("Update", mock.Anything).Once().Return(nil, assert.AnError)
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.Anything).Once().Return(nil, assert.AnError)
+	expectRollback()
 
 	// when:
-	err := s.DeleteMessageById(mockContext(), id)
+	err := s.DeleteMessageById(mockContext(), id, 0)
 
 	// then:
 	assert.NotNil(t, err)
 	assert.Equal(t, err, unexpectedErr)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestMessageServiceImpl_ListMessages_Ok(t *testing.T) {
+	// given:
+	now := time.Now()
+	messages := []model.Message{
+		{Id: 3, Text: "MOCK_TEXT_3", Status: "CREATED", CreatedAt: now},
+		{Id: 2, Text: "MOCK_TEXT_2", Status: "CREATED", CreatedAt: now},
+		{Id: 1, Text: "MOCK_TEXT_1", Status: "CREATED", CreatedAt: now},
+	}
+	mockRepo.On("List", mock.Anything, ownedBy("", model.MessageFilter{}), (*model.MessageCursor)(nil), 3).Once().Return(messages, nil)
+
+	// when:
+	result, err := s.ListMessages(mockContext(), model.MessageFilter{}, "", 2)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, messages[:2], result.Items)
+	next, err := model.DecodeMessageCursor(result.Next)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), next.Id)
+	assert.True(t, now.Equal(next.CreatedAt))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessages_OwnMessages(t *testing.T) {
+	// given:
+	messages := []model.Message{{Id: 1, Text: "MOCK_TEXT", Status: "CREATED", OwnerId: "MOCK_SUBJECT"}}
+	mockRepo.On("List", mock.Anything, ownedBy("MOCK_SUBJECT", model.MessageFilter{}), (*model.MessageCursor)(nil), DefaultPageSize+1).
+		Once().Return(messages, nil)
+	mockRepo.On("List", mock.Anything, model.MessageFilter{}, (*model.MessageCursor)(nil), DefaultPageSize+1).
+		Once().Return(messages, nil)
+
+	// when:
+	_, userErr := s.ListMessages(mockUserContext("MOCK_SUBJECT"), model.MessageFilter{}, "", 0)
+	_, adminErr := s.ListMessages(mockAdminContext(), model.MessageFilter{}, "", 0)
+
+	// then:
+	assert.Nil(t, userErr)
+	assert.Nil(t, adminErr)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessages_LastPage(t *testing.T) {
+	// given:
+	cursor := model.MessageCursor{CreatedAt: time.Now(), Id: 2}
+	messages := []model.Message{{Id: 1, Text: "MOCK_TEXT", Status: "CREATED"}}
+	mockRepo.On("List", mock.Anything, ownedBy("", model.MessageFilter{}), mock.MatchedBy(func(c *model.MessageCursor) bool {
+		return c != nil && c.Id == cursor.Id && c.CreatedAt.Equal(cursor.CreatedAt)
+	}), DefaultPageSize+1).Once().Return(messages, nil)
+
+	// when:
+	result, err := s.ListMessages(mockContext(), model.MessageFilter{}, cursor.Encode(), 0)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, messages, result.Items)
+	assert.Empty(t, result.Next)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessages_Search(t *testing.T) {
+	// given:
+	filter := model.MessageFilter{Status: model.CREATED, Query: "MOCK"}
+	cursor := model.MessageCursor{Offset: 2}
+	messages := []model.Message{
+		{Id: 5, Text: "MOCK MOCK", Status: "CREATED"},
+		{Id: 7, Text: "MOCK", Status: "CREATED"},
+		{Id: 6, Text: "MOCK TEXT", Status: "CREATED"},
+	}
+	mockRepo.On("Search", mock.Anything, ownedBy("", filter), 2, 3).Once().Return(messages, nil)
+
+	// when:
+	result, err := s.ListMessages(mockContext(), filter, cursor.Encode(), 2)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, messages[:2], result.Items)
+	next, err := model.DecodeMessageCursor(result.Next)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, next.Offset)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessages_InvalidCursor(t *testing.T) {
+	// when:
+	result, err := s.ListMessages(mockContext(), model.MessageFilter{}, "INVALID_CURSOR", 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+	assert.Equal(t, "error.go-example.invalid-cursor", err.Error())
+	assert.Equal(t, 400, err.(*ctmerror.MessageError).HttpCode())
+}
+
+func TestMessageServiceImpl_ListMessages_CursorOfOtherKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter model.MessageFilter
+		cursor model.MessageCursor
+	}{
+		{"negative offset", model.MessageFilter{Query: "MOCK"}, model.MessageCursor{Offset: -1}},
+		{"keyset cursor in search", model.MessageFilter{Query: "MOCK"}, model.MessageCursor{CreatedAt: time.Now(), Id: 1}},
+		{"offset cursor in listing", model.MessageFilter{}, model.MessageCursor{Offset: 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// when:
+			result, err := s.ListMessages(mockContext(), tt.filter, tt.cursor.Encode(), 0)
+
+			// then:
+			assert.Nil(t, result)
+			assert.Equal(t, "error.go-example.invalid-cursor", err.Error())
+			assert.Equal(t, 400, err.(*ctmerror.MessageError).HttpCode())
+		})
+	}
+}
+
+func TestMessageServiceImpl_ListMessages_Error(t *testing.T) {
+	// given:
+	mockRepo.On("List", mock.Anything, mock.Anything, mock.Anything, MaxPageSize+1).Once().Return(nil, assert.AnError)
+
+	// when:
+	result, err := s.ListMessages(mockContext(), model.MessageFilter{}, "", 1000)
+
+	// then:
+	assert.Nil(t, result)
+	assert.NotNil(t, err)
+	assert.Equal(t, err, unexpectedErr)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_GetMessageById_Deleted(t *testing.T) {
+	// given:
+	mockRepo.On("Get", mock.Anything, id).Once().Return(deletedMessage(), nil)
+
+	// when:
+	result, err := s.GetMessageById(mockContext(), id, false)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, err, notFoundErr)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_GetMessageById_IncludeDeleted(t *testing.T) {
+	// given:
+	mockRepo.On("Get", mock.Anything, id).Once().Return(deletedMessage(), nil)
+
+	// when:
+	result, err := s.GetMessageById(mockAdminContext(), id, true)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, model.DELETED, result.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_GetMessageById_IncludeDeletedForbidden(t *testing.T) {
+	// when:
+	result, err := s.GetMessageById(mockContext(), id, true)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.forbidden", err.Error())
+	assert.Equal(t, 403, err.(*ctmerror.MessageError).HttpCode())
+}
+
+func TestMessageServiceImpl_UpdateMessageById_Deleted(t *testing.T) {
+	// given:
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(deletedMessage(), nil)
+	expectRollback()
+
+	// when:
+	result, err := s.UpdateMessageById(mockContext(), id, model.Message{Text: "UPDATED_TEXT"}, 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, err, notFoundErr)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_DeleteMessageById_Deleted(t *testing.T) {
+	// given:
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(deletedMessage(), nil)
+	expectRollback()
+
+	// when:
+	err := s.DeleteMessageById(mockContext(), id, 0)
+
+	// then:
+	assert.Equal(t, err, notFoundErr)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_RestoreMessageById_Ok(t *testing.T) {
+	// given:
+	restoredMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(deletedMessage(), nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.Id == id && msg.Status == model.CREATED
+	})).Once().Return(&restoredMessage, nil)
+	expectCommit()
+
+	// when:
+	result, err := s.RestoreMessageById(mockContext(), id)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, model.CREATED, result.Status)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_RestoreMessageById_NotDeleted(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	result, err := s.RestoreMessageById(mockContext(), id)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.message-not-deleted", err.Error())
+	assert.Equal(t, 409, err.(*ctmerror.MessageError).HttpCode())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessages_IncludeDeletedForbidden(t *testing.T) {
+	// when:
+	result, err := s.ListMessages(mockContext(), model.MessageFilter{IncludeDeleted: true}, "", 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.forbidden", err.Error())
+}
+
+func TestMessageServiceImpl_UpdateMessageById_VersionMismatch(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 3,
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	result, err := s.UpdateMessageById(mockContext(), id, model.Message{Text: "UPDATED_TEXT"}, 2)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.precondition-failed", err.Error())
+	assert.Equal(t, 412, err.(*ctmerror.MessageError).HttpCode())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_UpdateMessageById_ConcurrentUpdate(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 3,
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.Anything).Once().Return(nil, model.ErrVersionConflict)
+	expectRollback()
+
+	// when:
+	result, err := s.UpdateMessageById(mockContext(), id, model.Message{Text: "UPDATED_TEXT"}, 3)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.precondition-failed", err.Error())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_DeleteMessageById_VersionMismatch(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 3,
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	err := s.DeleteMessageById(mockContext(), id, 4)
+
+	// then:
+	assert.Equal(t, "error.go-example.precondition-failed", err.Error())
+	assert.Equal(t, 412, err.(*ctmerror.MessageError).HttpCode())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_PatchMessageById_MergePatch(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 2,
+	}
+	patch := model.MessagePatch{Type: model.MergePatch, Document: []byte(`{"text":"PATCHED_TEXT"}`)}
+
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.Id == id &&
+			msg.Text == "PATCHED_TEXT" &&
+			msg.Status == model.CREATED
+	})).Once().Return(&model.Message{Id: id, Text: "PATCHED_TEXT", Status: "CREATED", Version: 3}, nil)
+	expectCommit()
+
+	// when:
+	result, err := s.PatchMessageById(mockContext(), id, patch, 2)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, "PATCHED_TEXT", result.Text)
+	assert.Equal(t, int64(3), result.Version)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_PatchMessageById_JSONPatch(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	patch := model.MessagePatch{
+		Type:     model.JSONPatch,
+		Document: []byte(`[{"op":"test","path":"/text","value":"MOCK_TEXT"},{"op":"replace","path":"/text","value":"PATCHED_TEXT"}]`),
+	}
+
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.Id == id && msg.Text == "PATCHED_TEXT"
+	})).Once().Return(&model.Message{Id: id, Text: "PATCHED_TEXT", Status: "CREATED"}, nil)
+	expectCommit()
+
+	// when:
+	result, err := s.PatchMessageById(mockContext(), id, patch, 0)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, "PATCHED_TEXT", result.Text)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_PatchMessageById_FailedTest(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	patch := model.MessagePatch{
+		Type:     model.JSONPatch,
+		Document: []byte(`[{"op":"test","path":"/text","value":"OTHER_TEXT"}]`),
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	result, err := s.PatchMessageById(mockContext(), id, patch, 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.invalid-patch", err.Error())
+	assert.Equal(t, 400, err.(*ctmerror.MessageError).HttpCode())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_PatchMessageById_ValidationFailed(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	patch := model.MessagePatch{Type: model.MergePatch, Document: []byte(`{"text":null}`)}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	expectRollback()
+
+	// when:
+	result, err := s.PatchMessageById(mockContext(), id, patch, 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.validation-failed", err.Error())
+	assert.Equal(t, "text", err.(*ctmerror.MessageError).Details()[0].Field)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_PatchMessageById_UnsupportedType(t *testing.T) {
+	// given:
+	patch := model.MessagePatch{Type: "text/plain", Document: []byte(`text`)}
+
+	// when:
+	result, err := s.PatchMessageById(mockContext(), id, patch, 0)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.unsupported-media-type", err.Error())
+	assert.Equal(t, 415, err.(*ctmerror.MessageError).HttpCode())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_UpdateMessageById_RecordsActor(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.UpdatedBy == model.ActorAdmin
+	})).Once().Return(&originalMessage, nil)
+	expectCommit()
+
+	// when:
+	_, err := s.UpdateMessageById(mockAdminContext(), id, model.Message{Text: "UPDATED_TEXT"}, 0)
+
+	// then:
+	assert.Nil(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_UpdateMessageById_RecordsSubject(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.UpdatedBy == "MOCK_SUBJECT"
+	})).Once().Return(&originalMessage, nil)
+	expectCommit()
+	ctx := context.WithValue(mockAdminContext(), model.ContextSubject, "MOCK_SUBJECT")
+
+	// when:
+	_, err := s.UpdateMessageById(ctx, id, model.Message{Text: "UPDATED_TEXT"}, 0)
+
+	// then:
+	assert.Nil(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessageRevisions_Ok(t *testing.T) {
+	// given:
+	revisions := []model.MessageRevision{
+		{MessageId: id, Revision: 1, Text: "MOCK_TEXT", Status: "CREATED"},
+		{MessageId: id, Revision: 2, Text: "UPDATED_TEXT", Status: "CREATED"},
+	}
+	mockRepo.On("Get", mock.Anything, id).Once().Return(&model.Message{Id: id, Status: "CREATED", Version: 2}, nil)
+	mockRepo.On("ListRevisions", mock.Anything, id).Once().Return(revisions, nil)
+
+	// when:
+	result, err := s.ListMessageRevisions(mockContext(), id)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, revisions, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_ListMessageRevisions_Deleted(t *testing.T) {
+	// given:
+	mockRepo.On("Get", mock.Anything, id).Once().Return(deletedMessage(), nil)
+
+	// when:
+	result, err := s.ListMessageRevisions(mockContext(), id)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, notFoundErr, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_GetMessageRevision_NotFound(t *testing.T) {
+	// given:
+	mockRepo.On("Get", mock.Anything, id).Once().Return(&model.Message{Id: id, Status: "CREATED"}, nil)
+	mockRepo.On("GetRevision", mock.Anything, id, int64(7)).Once().Return(nil, pg.ErrNoRows)
+
+	// when:
+	result, err := s.GetMessageRevision(mockContext(), id, 7)
+
+	// then:
+	assert.Nil(t, result)
+	assert.Equal(t, "error.go-example.revision-not-found", err.Error())
+	assert.Equal(t, 404, err.(*ctmerror.MessageError).HttpCode())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_RevertMessageById_Ok(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:      id,
+		Text:    "UPDATED_TEXT",
+		Status:  "CREATED",
+		Version: 2,
+	}
+	revision := model.MessageRevision{MessageId: id, Revision: 1, Text: "MOCK_TEXT", Status: "CREATED"}
+	revertedMessage := model.Message{
+		Id:      id,
+		Text:    "MOCK_TEXT",
+		Status:  "CREATED",
+		Version: 3,
+	}
+
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("GetRevision", mock.Anything, id, int64(1)).Once().Return(&revision, nil)
+	mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(msg *model.Message) bool {
+		return msg.Id == id &&
+			msg.Text == revision.Text &&
+			msg.Version == 2
+	})).Once().Return(&revertedMessage, nil)
+	expectCommit()
+
+	// when:
+	result, err := s.RevertMessageById(mockContext(), id, 1, 2)
+
+	// then:
+	assert.Nil(t, err)
+	assert.Equal(t, &revertedMessage, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_DeleteMessageById_CommitFailed(t *testing.T) {
+	// given:
+	originalMessage := model.Message{
+		Id:     id,
+		Text:   "MOCK_TEXT",
+		Status: "CREATED",
+	}
+	mockRepo.On("GetForUpdate", mock.Anything, id).Once().Return(&originalMessage, nil)
+	mockRepo.On("Update", mock.Anything, mock.Anything).Once().Return(&originalMessage, nil)
+	mockRepo.On("RunInTx", mock.Anything).Once()
+	mockRepo.On("Commit").Once().Return(assert.AnError)
+
+	// when:
+	err := s.DeleteMessageById(mockContext(), id, 0)
+
+	// then:
+	assert.Equal(t, unexpectedErr, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMessageServiceImpl_GetMessageById_Cancelled(t *testing.T) {
+	cases := []struct {
+		err       error
+		errorCode string
+		httpCode  int
+	}{
+		{fmt.Errorf("pg: canceling statement: %w", context.Canceled), "error.go-example.request-canceled", 499},
+		{fmt.Errorf("pg: canceling statement: %w", context.DeadlineExceeded), "error.go-example.timeout", 504},
+	}
+
+	for _, c := range cases {
+		// given:
+		mockRepo.On("Get", mock.Anything, id).Once().Return(nil, c.err)
+
+		// when:
+		result, err := s.GetMessageById(mockContext(), id, false)
+
+		// then:
+		assert.Nil(t, result)
+		assert.Equal(t, c.errorCode, err.Error())
+		assert.Equal(t, c.httpCode, err.(*ctmerror.MessageError).HttpCode())
+		mockRepo.AssertExpectations(t)
+	}
+}
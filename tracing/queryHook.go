@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-pg/pg"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type spanKey struct{}
+
+// QueryHook is go-pg query hook creating a client span per Db query, as a child of the query context span
+type QueryHook struct{}
+
+var _ pg.QueryHook = QueryHook{}
+
+func (QueryHook) BeforeQuery(event *pg.QueryEvent) {
+	ctx := event.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	_, span := Tracer().Start(ctx, "db.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL))
+	event.Data[spanKey{}] = span
+}
+
+func (QueryHook) AfterQuery(event *pg.QueryEvent) {
+	span, ok := event.Data[spanKey{}].(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// unformatted query keeps parameter values out of the spans
+	if query, err := event.UnformattedQuery(); err == nil {
+		span.SetAttributes(semconv.DBStatementKey.String(query))
+	}
+	if event.Error != nil && event.Error != pg.ErrNoRows {
+		span.RecordError(event.Error)
+		span.SetStatus(codes.Error, event.Error.Error())
+	}
+}
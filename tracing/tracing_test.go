@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/go-pg/pg"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"testing"
+)
+
+func TestInit_PropagatesB3(t *testing.T) {
+	// given:
+	shutdown, err := Init(Config{Exporter: ExporterNone})
+	assert.Nil(t, err)
+	defer shutdown(context.Background())
+
+	header := http.Header{}
+	header.Set("x-b3-traceid", "463ac35c9f6413ad48485a3953bb6124")
+	header.Set("x-b3-spanid", "a2fb4a1d1a96d312")
+	header.Set("x-b3-sampled", "1")
+
+	// when:
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	// then:
+	spanContext := trace.SpanContextFromContext(ctx)
+	assert.Equal(t, "463ac35c9f6413ad48485a3953bb6124", spanContext.TraceID().String())
+	assert.Equal(t, "a2fb4a1d1a96d312", spanContext.SpanID().String())
+}
+
+func TestInit_UnknownExporter(t *testing.T) {
+	// when:
+	_, err := Init(Config{Exporter: "zipkin"})
+
+	// then:
+	assert.NotNil(t, err)
+}
+
+func TestQueryHook_ChildSpan(t *testing.T) {
+	// given:
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+	event := &pg.QueryEvent{
+		Ctx:   ctx,
+		Query: "SELECT 1",
+		Data:  map[interface{}]interface{}{},
+	}
+
+	// when:
+	QueryHook{}.BeforeQuery(event)
+	event.Error = errors.New("connection reset")
+	QueryHook{}.AfterQuery(event)
+	parent.End()
+
+	// then:
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	query := spans[0]
+	assert.Equal(t, "db.query", query.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), query.Parent().SpanID())
+	assert.Equal(t, codes.Error, query.Status().Code)
+}
@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// InvalidBodyErrorCode is the error code of malformed JSON documents
+const InvalidBodyErrorCode = "error.go-example.invalid-request-body"
+
+// Decode strictly decodes JSON document into v, rejecting unknown fields, and validates it
+func Decode(r io.Reader, v interface{}) *ctmerror.MessageError {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if err != nil {
+		return invalidBody(err)
+	}
+	return Validate(v)
+}
+
+func invalidBody(err error) *ctmerror.MessageError {
+	msgError := ctmerror.NewMessageErrorBuilder(InvalidBodyErrorCode, err, http.StatusBadRequest)
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return msgError.WithDetails(ctmerror.FieldError{
+			Field:   typeErr.Field,
+			Code:    "type",
+			Message: "must be of type " + typeErr.Type.String(),
+		})
+	}
+
+	// encoding/json reports unknown fields only by message: json: unknown field "name"
+	const unknownFieldPrefix = "json: unknown field "
+	if strings.HasPrefix(err.Error(), unknownFieldPrefix) {
+		return msgError.WithDetails(ctmerror.FieldError{
+			Field:   strings.Trim(strings.TrimPrefix(err.Error(), unknownFieldPrefix), `"`),
+			Code:    "unknown",
+			Message: "is not a known field",
+		})
+	}
+	return msgError
+}
@@ -0,0 +1,85 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ErrorCode is the error code of requests violating validation rules
+const ErrorCode = "error.go-example.validation-failed"
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("notblank", validators.NotBlank)
+
+	// report fields by their json names, as clients know them
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	return v
+}
+
+// Validate checks s against rules declared in its `validate` struct tags,
+// violations are returned as MessageError with per-field details
+func Validate(s interface{}) *ctmerror.MessageError {
+	err := validate.Struct(s)
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return ctmerror.NewMessageError(err)
+	}
+
+	msgError := ctmerror.NewMessageErrorBuilder(ErrorCode, err, http.StatusUnprocessableEntity)
+	for _, fe := range fieldErrors {
+		msgError.WithDetails(ctmerror.FieldError{
+			Field:   fieldPath(fe),
+			Code:    fe.Tag(),
+			Message: describe(fe),
+		})
+	}
+	return msgError
+}
+
+// fieldPath returns json path of the field without the top level struct name
+func fieldPath(fe validator.FieldError) string {
+	path := fe.Namespace()
+	if i := strings.Index(path, "."); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+func describe(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "must not be empty"
+	case "notblank":
+		return "must not be blank"
+	case "max":
+		return fmt.Sprintf("must be at most %s characters long", fe.Param())
+	case "min":
+		return fmt.Sprintf("must be at least %s characters long", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	default:
+		return fmt.Sprintf("must satisfy %s rule", fe.Tag())
+	}
+}
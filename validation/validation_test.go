@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"github.com/FatimaBabayeva/ms-go-example/ctmerror"
+	"github.com/FatimaBabayeva/ms-go-example/model"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidate_Ok(t *testing.T) {
+	// when:
+	err := Validate(model.CreateMessageRequest{Text: "MOCK_TEXT"})
+
+	// then:
+	assert.Nil(t, err)
+}
+
+func TestValidate_InvalidMessage(t *testing.T) {
+	cases := []struct {
+		request interface{}
+		details []ctmerror.FieldError
+	}{
+		{
+			model.CreateMessageRequest{},
+			[]ctmerror.FieldError{{Field: "text", Code: "notblank", Message: "must not be blank"}},
+		},
+		{
+			&model.UpdateMessageRequest{Text: "   "},
+			[]ctmerror.FieldError{{Field: "text", Code: "notblank", Message: "must not be blank"}},
+		},
+		{
+			model.UpdateMessageRequest{Text: strings.Repeat("ё", 257)},
+			[]ctmerror.FieldError{{Field: "text", Code: "max", Message: "must be at most 256 characters long"}},
+		},
+	}
+
+	for _, c := range cases {
+		// when:
+		err := Validate(c.request)
+
+		// then:
+		assert.NotNil(t, err)
+		assert.Equal(t, ErrorCode, err.Error())
+		assert.Equal(t, 422, err.HttpCode())
+		assert.Equal(t, c.details, err.Details())
+	}
+}